@@ -0,0 +1,36 @@
+package api
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// getEnvInt reads an integer environment variable, falling back to def when unset or invalid
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// getEnvDuration reads a duration environment variable such as "500ms" or "30s".
+// A bare number is interpreted as seconds.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if n, err := strconv.Atoi(value); err == nil {
+		return time.Duration(n) * time.Second
+	}
+	return def
+}
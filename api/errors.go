@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error types used in OpenAI-compatible error payloads
+const (
+	errTypeInvalidRequest = "invalid_request_error"
+	errTypeUpstream       = "upstream_error"
+	errTypeTool           = "tool_error"
+	errTypeServer         = "server_error"
+)
+
+// APIError represents an OpenAI-compatible error object.
+// Code is passed through from upstream errors and is null otherwise.
+type APIError struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Code    interface{} `json:"code"`
+}
+
+// ErrorResponse wraps an APIError the way OpenAI does
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// respondError writes an OpenAI-compatible JSON error response
+func respondError(c *gin.Context, status int, errType string, message string) {
+	c.JSON(status, ErrorResponse{Error: APIError{
+		Message: message,
+		Type:    errType,
+	}})
+}
+
+// writeStreamError emits an OpenAI-style error chunk on an already started SSE stream
+func writeStreamError(c *gin.Context, errType string, message string) {
+	data, err := json.Marshal(ErrorResponse{Error: APIError{
+		Message: message,
+		Type:    errType,
+	}})
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
+
+// readUpstreamError extracts an APIError from a non-200 upstream response.
+// Bodies that are not OpenAI-style JSON are wrapped as plain messages.
+func readUpstreamError(resp *http.Response) APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var upstream struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &upstream); err == nil && len(upstream.Error) > 0 {
+		var apiErr APIError
+		if err := json.Unmarshal(upstream.Error, &apiErr); err == nil && apiErr.Message != "" {
+			if apiErr.Type == "" {
+				apiErr.Type = errTypeUpstream
+			}
+			return apiErr
+		}
+		// Some providers send the error as a bare string
+		var message string
+		if err := json.Unmarshal(upstream.Error, &message); err == nil && message != "" {
+			return APIError{Message: message, Type: errTypeUpstream}
+		}
+	}
+
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return APIError{
+		Message: fmt.Sprintf("upstream returned status %d: %s", resp.StatusCode, message),
+		Type:    errTypeUpstream,
+	}
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadUpstreamError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        APIError
+	}{
+		{
+			name:        "OpenAI error is passed through",
+			status:      http.StatusTooManyRequests,
+			contentType: "application/json",
+			body:        `{"error":{"message":"Rate limit reached for gpt-4o","type":"requests","param":null,"code":"rate_limit_exceeded"}}`,
+			want:        APIError{Message: "Rate limit reached for gpt-4o", Type: "requests", Code: "rate_limit_exceeded"},
+		},
+		{
+			name:        "missing type becomes upstream_error",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"error":{"message":"model not found","code":404}}`,
+			want:        APIError{Message: "model not found", Type: errTypeUpstream, Code: float64(404)},
+		},
+		{
+			name:        "bare string error",
+			status:      http.StatusUnauthorized,
+			contentType: "application/json",
+			body:        `{"error":"invalid api key"}`,
+			want:        APIError{Message: "invalid api key", Type: errTypeUpstream},
+		},
+		{
+			name:        "JSON without an error object",
+			status:      http.StatusUnprocessableEntity,
+			contentType: "application/json",
+			body:        `{"detail":"messages must not be empty"}`,
+			want:        APIError{Message: `upstream returned status 422: {"detail":"messages must not be empty"}`, Type: errTypeUpstream},
+		},
+		{
+			name:        "non-JSON body",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html><body>502 Bad Gateway</body></html>\n",
+			want:        APIError{Message: "upstream returned status 502: <html><body>502 Bad Gateway</body></html>", Type: errTypeUpstream},
+		},
+		{
+			name:   "empty body",
+			status: http.StatusServiceUnavailable,
+			want:   APIError{Message: "upstream returned status 503: Service Unavailable", Type: errTypeUpstream},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if got := readUpstreamError(resp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readUpstreamError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	respondError(c, http.StatusBadRequest, errTypeInvalidRequest, "messages is required")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": "messages is required",
+			"type":    errTypeInvalidRequest,
+			"code":    nil,
+		},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestWriteStreamError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	// The error follows content already streamed to the client
+	c.Header("Content-Type", "text/event-stream")
+	c.Writer.WriteString("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Go 1.23\"}}]}\n\n")
+	writeStreamError(c, errTypeTool, "error executing tool calls: search failed")
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if !w.Flushed {
+		t.Error("error event was not flushed")
+	}
+
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %q", len(events), w.Body.String())
+	}
+	data, ok := strings.CutPrefix(events[1], "data: ")
+	if !ok {
+		t.Fatalf("error event = %q, want a data line", events[1])
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": "error executing tool calls: search failed",
+			"type":    errTypeTool,
+			"code":    nil,
+		},
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("error event = %v, want %v", body, want)
+	}
+}
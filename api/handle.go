@@ -13,73 +13,132 @@ import (
 	"github.com/liyown/search4ai-go/stream"
 )
 
-func handleStreamingResponse(c *gin.Context, resp *http.Response, req *ChatCompletionRequest) {
+// setStreamHeaders starts an SSE response
+func setStreamHeaders(c *gin.Context, status int) {
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
 	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.WriteHeader(resp.StatusCode)
-	var searchResults []map[string]interface{}
+	c.Writer.WriteHeader(status)
+}
+
+func handleStreamingResponse(c *gin.Context, resp *http.Response, req *ChatCompletionRequest) {
+	setStreamHeaders(c, resp.StatusCode)
+
+	apiKey := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	session := newToolSession(c.Request.Context(), apiKey, req.Options)
+	session.conversation = lastUserText(req.Messages)
+	var citations *stream.CitationTracker
+	if req.Options.Citations {
+		citations = stream.NewCitationTracker(session.hasSource)
+	}
+	if req.Options.ToolProgress {
+		session.onProgress = func(event stream.ProgressEvent) {
+			stream.WriteProgress(c.Writer, req.Model, event)
+		}
+	}
+
+	// resp is replaced on every tool round, so close whichever body is current on exit
+	defer func() { resp.Body.Close() }()
 
 	for {
 		processor := stream.NewProcessor(c.Writer)
-		message, collectedTools, needsToolExecution := processor.ProcessStream(resp.Body, searchResults)
+		if citations != nil {
+			processor.SetCitationTracker(citations)
+		}
+		message, collectedTools, needsToolExecution := processor.ProcessStream(resp.Body)
+		if err := processor.Err(); err != nil {
+			writeStreamError(c, errTypeUpstream, err.Error())
+		}
 
 		// If no tool execution is needed, we're done
 		if !needsToolExecution {
+			if req.Options.SearchResultsPlacement == placementFinal && len(session.searchResults) > 0 {
+				stream.WriteSearchResults(c.Writer, req.Model, session.searchResults)
+			}
+			if req.Options.SearchResultsPlacement == placementFinal && len(session.crawlResults) > 0 {
+				stream.WriteCrawlResults(c.Writer, req.Model, session.crawlResults)
+			}
+			if citations != nil {
+				stream.WriteCitations(c.Writer, req.Model, session.buildCitations(citations.Used()))
+			}
 			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 			return
 		}
 
 		// Add the assistant's tool calls message to the conversation
-		req.Messages = append(req.Messages, map[string]interface{}{
+		assistantMessage := map[string]interface{}{
 			"role":       "assistant",
 			"tool_calls": message.ToolCalls,
-		})
+		}
+		// Keep any text the model produced alongside its tool calls
+		if message.Content != "" {
+			assistantMessage["content"] = message.Content
+		}
+		req.Messages = append(req.Messages, assistantMessage)
 
 		// Execute collected tool calls
 		toolCallsInterface := make([]interface{}, len(collectedTools))
 		for i, v := range collectedTools {
 			toolCallsInterface[i] = v
 		}
-		toolResults, err := executeToolCalls(toolCallsInterface)
+		toolResults, err := executeToolCalls(toolCallsInterface, session)
 		if err != nil {
 			log.Printf("Error executing tool calls: %v", err)
+			writeStreamError(c, errTypeTool, fmt.Sprintf("error executing tool calls: %v", err))
 			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 			return
 		}
 
-		searchResults = toolResults
+		// Send results found in this round once, ahead of the answer that uses them
+		if req.Options.SearchResultsPlacement == placementDedicated {
+			if results := session.unsentSearchResults(); len(results) > 0 {
+				stream.WriteSearchResults(c.Writer, req.Model, results)
+			}
+			if results := session.unsentCrawlResults(); len(results) > 0 {
+				stream.WriteCrawlResults(c.Writer, req.Model, results)
+			}
+		}
 
 		// Add tool results to the conversation
 		req.Messages = append(req.Messages, toolResults...)
 
 		// Make a new request with the updated context
-		newResp, err := forwardToOpenAI(req, strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		newResp, err := forwardToOpenAI(c.Request.Context(), req, apiKey)
 		if err != nil {
 			log.Printf("Error making recursive request: %v", err)
+			writeStreamError(c, errTypeUpstream, err.Error())
 			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
 			return
 		}
 		resp.Body.Close()
 		resp = newResp
 
+		// The stream has already started, so upstream errors can only be reported in-band
+		if resp.StatusCode != http.StatusOK {
+			apiErr := readUpstreamError(resp)
+			log.Printf("Upstream error during tool round: %s", apiErr.Message)
+			writeStreamError(c, apiErr.Type, apiErr.Message)
+			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+			return
+		}
 	}
 }
 
 func handleNonStreamingResponse(c *gin.Context, resp *http.Response, req *ChatCompletionRequest) {
 	var openaiResp ChatCompletionResponseWithSearchResults
 	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "error parsing OpenAI response"})
+		respondError(c, http.StatusBadGateway, errTypeUpstream, "error parsing OpenAI response")
 		return
 	}
 
 	// Check for tool calls
 	if len(openaiResp.Choices) > 0 && openaiResp.Choices[0].Message != nil {
 		if toolCalls, ok := openaiResp.Choices[0].Message["tool_calls"].([]interface{}); ok {
-			toolResults, err := executeToolCalls(toolCalls)
+			session := getToolSession(c, req)
+			toolResults, err := executeToolCalls(toolCalls, session)
 			if err != nil {
 				log.Printf("error executing tool calls: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "error executing tool calls"})
+				respondError(c, http.StatusInternalServerError, errTypeTool, "error executing tool calls")
 				return
 			}
 
@@ -90,13 +149,10 @@ func handleNonStreamingResponse(c *gin.Context, resp *http.Response, req *ChatCo
 
 				body, err := json.Marshal(req)
 				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "error preparing tool results"})
+					respondError(c, http.StatusInternalServerError, errTypeServer, "error preparing tool results")
 					return
 				}
 
-				// update search results
-				c.Set("searchResults", toolResults)
-
 				c.Request.Body = io.NopCloser(bytes.NewReader(body))
 				// 使用递归请求处理工具结果
 				handleChatCompletions(c)
@@ -107,30 +163,68 @@ func handleNonStreamingResponse(c *gin.Context, resp *http.Response, req *ChatCo
 
 	}
 
-	// update search results
-	searchResults, _ := c.Get("searchResults")
-	openaiResp.SearchResults = searchResults.([]map[string]interface{})
+	// Attach the search results and crawled pages gathered across all tool rounds
+	session := getToolSession(c, req)
+	openaiResp.SearchResults = session.searchResults
+	openaiResp.CrawlResults = session.crawlResults
+
+	// Validate citation markers in the final answer
+	if req.Options.Citations && len(openaiResp.Choices) > 0 {
+		if content, ok := openaiResp.Choices[0].Message["content"].(string); ok {
+			tracker := stream.NewCitationTracker(session.hasSource)
+			openaiResp.Choices[0].Message["content"] = tracker.Filter(content) + tracker.Flush()
+			openaiResp.Citations = session.buildCitations(tracker.Used())
+		}
+	}
 
 	c.JSON(resp.StatusCode, openaiResp)
 }
 
+// getToolSession returns the tool session shared by the recursive non-streaming requests
+func getToolSession(c *gin.Context, req *ChatCompletionRequest) *toolSession {
+	if value, ok := c.Get("toolSession"); ok {
+		if session, ok := value.(*toolSession); ok {
+			return session
+		}
+	}
+	apiKey := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	session := newToolSession(c.Request.Context(), apiKey, req.Options)
+	session.conversation = lastUserText(req.Messages)
+	c.Set("toolSession", session)
+	return session
+}
+
 // handleChatCompletions handles the chat completions endpoint
 func handleChatCompletions(c *gin.Context) {
 	// Validate and prepare request
 	req, apiKey, err := prepareRequest(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, errTypeInvalidRequest, err.Error())
+		return
+	}
+
+	if req.research {
+		runResearch(c, req, apiKey)
 		return
 	}
 
 	// Forward request to OpenAI
-	resp, err := forwardToOpenAI(req, apiKey)
+	resp, err := forwardToOpenAI(c.Request.Context(), req, apiKey)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.Printf("Error forwarding request: %v", err)
+		respondError(c, http.StatusBadGateway, errTypeUpstream, err.Error())
 		return
 	}
 	defer resp.Body.Close()
 
+	// Report upstream errors as proper HTTP errors before any streaming begins
+	if resp.StatusCode != http.StatusOK {
+		apiErr := readUpstreamError(resp)
+		log.Printf("Upstream error: %s", apiErr.Message)
+		c.JSON(resp.StatusCode, ErrorResponse{Error: apiErr})
+		return
+	}
+
 	// Handle response based on streaming flag
 	if req.Stream {
 		handleStreamingResponse(c, resp, req)
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// citationPrompt instructs the model to cite numbered search results
+const citationPrompt = "回答时请引用搜索结果中的来源：在使用了某个来源信息的句子末尾标注 [n]，n 为工具结果中该来源的编号，例如 [1] 或 [2][3]。只能使用工具结果中实际存在的编号，不要编造来源。"
+
+// addSystemPrompt merges text into the first system message, or prepends a new
+// system message when the conversation has none
+func addSystemPrompt(req *ChatCompletionRequest, text string) {
+	for _, message := range req.Messages {
+		if message["role"] != "system" {
+			continue
+		}
+		switch content := message["content"].(type) {
+		case string:
+			if content == "" {
+				message["content"] = text
+			} else {
+				message["content"] = content + "\n\n" + text
+			}
+			return
+		case []interface{}:
+			message["content"] = append(content, map[string]interface{}{
+				"type": "text",
+				"text": text,
+			})
+			return
+		}
+	}
+
+	req.Messages = append([]map[string]interface{}{
+		{
+			"role":    "system",
+			"content": text,
+		},
+	}, req.Messages...)
+}
+
+// defaultPromptTemplate is used when no template is configured. Tool guidance
+// is only given for the tools advertised in the request.
+const defaultPromptTemplate = `当前日期：{{.Date}}（{{.Weekday}}），当前时间：{{.Time}}（时区 {{.Timezone}}）。
+{{- if .Locale}}
+用户的语言区域为 {{.Locale}}，除非用户另有要求，请使用该语言回答。
+{{- end}}
+{{- if .Tools.search}}
+当问题涉及实时信息、近期事件、或你不确定是否仍然正确的事实时，请先使用 search 工具搜索，不要凭训练数据回答。
+{{- end}}
+{{- if .Tools.news_search}}
+询问新闻或最新进展时，请使用 news_search 工具。
+{{- end}}
+{{- if .Tools.crawler}}
+当需要阅读某个网页的完整内容时（例如用户给出链接，或搜索摘要不足以回答），请使用 crawler 工具。
+{{- end}}`
+
+// promptData is the data available to system prompt templates
+type promptData struct {
+	Date      string
+	Time      string
+	Weekday   string
+	Timezone  string
+	Locale    string
+	Model     string
+	Citations bool
+	// Tools holds the names of the tools advertised in the request
+	Tools map[string]bool
+}
+
+// promptConfig selects system prompt templates per API key or model.
+// It is loaded from the JSON file named by SYSTEM_PROMPT_FILE.
+type promptConfig struct {
+	// Default names the template used when no key or model rule matches
+	Default   string            `json:"default"`
+	Templates map[string]string `json:"templates"`
+	// Keys maps client API keys to template names
+	Keys map[string]string `json:"keys"`
+	// Models maps model names to template names; a trailing * matches a prefix
+	Models map[string]string `json:"models"`
+
+	parsed   map[string]*template.Template
+	location *time.Location
+}
+
+var (
+	promptOnce sync.Once
+	promptCfg  *promptConfig
+)
+
+// getPromptConfig loads the system prompt configuration, or returns nil when injection is disabled
+func getPromptConfig() *promptConfig {
+	promptOnce.Do(func() {
+		cfg := &promptConfig{
+			Templates: make(map[string]string),
+		}
+
+		if path := os.Getenv("SYSTEM_PROMPT_FILE"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("Error reading system prompt file: %v", err)
+				return
+			}
+			if err := json.Unmarshal(data, cfg); err != nil {
+				log.Printf("Error parsing system prompt file: %v", err)
+				return
+			}
+		} else if os.Getenv("SYSTEM_PROMPT") != "true" {
+			return
+		}
+
+		if inline := os.Getenv("SYSTEM_PROMPT_TEMPLATE"); inline != "" {
+			cfg.Templates["default"] = inline
+		}
+		if _, ok := cfg.Templates["default"]; !ok {
+			cfg.Templates["default"] = defaultPromptTemplate
+		}
+		if cfg.Default == "" {
+			cfg.Default = "default"
+		}
+
+		cfg.parsed = make(map[string]*template.Template)
+		for name, text := range cfg.Templates {
+			// Allow "\n" escapes in templates given through the environment
+			tmpl, err := template.New(name).Parse(strings.ReplaceAll(text, `\n`, "\n"))
+			if err != nil {
+				log.Printf("Error parsing system prompt template %s: %v", name, err)
+				continue
+			}
+			cfg.parsed[name] = tmpl
+		}
+
+		cfg.location = time.Local
+		if tz := os.Getenv("TIMEZONE"); tz != "" {
+			location, err := time.LoadLocation(tz)
+			if err != nil {
+				log.Printf("Invalid TIMEZONE %s: %v", tz, err)
+			} else {
+				cfg.location = location
+			}
+		}
+
+		promptCfg = cfg
+	})
+	return promptCfg
+}
+
+// selectTemplate picks the template for an API key and model.
+// A rule naming "none" disables injection.
+func (cfg *promptConfig) selectTemplate(apiKey string, model string) *template.Template {
+	name := cfg.Default
+	if byKey, ok := cfg.Keys[apiKey]; ok {
+		name = byKey
+	} else if byModel, ok := cfg.matchModel(model); ok {
+		name = byModel
+	}
+	if name == "none" {
+		return nil
+	}
+	return cfg.parsed[name]
+}
+
+// matchModel finds the template name for a model, preferring exact matches
+// and then the longest matching prefix pattern
+func (cfg *promptConfig) matchModel(model string) (string, bool) {
+	if name, ok := cfg.Models[model]; ok {
+		return name, true
+	}
+	best, bestLen := "", -1
+	for pattern, name := range cfg.Models {
+		prefix := strings.TrimSuffix(pattern, "*")
+		if prefix != pattern && strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = name, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// injectSystemPrompt renders the configured template and merges it into the request
+func injectSystemPrompt(req *ChatCompletionRequest, apiKey string, locale string) {
+	cfg := getPromptConfig()
+	if cfg == nil {
+		return
+	}
+	tmpl := cfg.selectTemplate(apiKey, req.Model)
+	if tmpl == nil {
+		return
+	}
+
+	now := time.Now().In(cfg.location)
+	data := promptData{
+		Date:      now.Format("2006-01-02"),
+		Time:      now.Format("15:04"),
+		Weekday:   now.Weekday().String(),
+		Timezone:  cfg.location.String(),
+		Locale:    locale,
+		Model:     req.Model,
+		Citations: req.Options.Citations,
+		Tools:     toolNames(req.Tools),
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		log.Printf("Error rendering system prompt: %v", err)
+		return
+	}
+	if text := strings.TrimSpace(sb.String()); text != "" {
+		addSystemPrompt(req, text)
+	}
+}
+
+// requestLocale returns the locale from the request options, the Accept-Language
+// header or the DEFAULT_LOCALE setting, in that order
+func requestLocale(options ProxyOptions, acceptLanguage string) string {
+	if options.Locale != "" {
+		return options.Locale
+	}
+	if acceptLanguage != "" {
+		tag := strings.TrimSpace(strings.Split(strings.Split(acceptLanguage, ",")[0], ";")[0])
+		if tag != "" && tag != "*" {
+			return tag
+		}
+	}
+	return os.Getenv("DEFAULT_LOCALE")
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestDefaultPromptToolGuidance(t *testing.T) {
+	tmpl := template.Must(template.New("default").Parse(defaultPromptTemplate))
+
+	tests := []struct {
+		name  string
+		tools []map[string]interface{}
+		want  []string
+		// absent lists tool names the prompt must not mention
+		absent []string
+	}{
+		{
+			name:  "built-in tools",
+			tools: buildTools(nil),
+			want:  []string{"search 工具", "news_search 工具", "crawler 工具"},
+		},
+		{
+			name: "client-supplied search only",
+			tools: []map[string]interface{}{
+				{"type": "function", "function": map[string]interface{}{"name": "search"}},
+				{"type": "function", "function": map[string]interface{}{"name": "get_weather"}},
+			},
+			want:   []string{"search 工具"},
+			absent: []string{"news_search", "crawler"},
+		},
+		{
+			name:   "no tools",
+			absent: []string{"search", "crawler"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if err := tmpl.Execute(&sb, promptData{Date: "2024-08-13", Tools: toolNames(tt.tools)}); err != nil {
+				t.Fatal(err)
+			}
+			prompt := sb.String()
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt %q does not mention %q", prompt, want)
+				}
+			}
+			for _, name := range tt.absent {
+				if strings.Contains(prompt, name) {
+					t.Errorf("prompt %q mentions %q", prompt, name)
+				}
+			}
+		})
+	}
+}
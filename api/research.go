@@ -0,0 +1,427 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/liyown/search4ai-go/stream"
+	"github.com/liyown/search4ai-go/units"
+)
+
+// researchSuffix on a model name routes a chat completion to deep research
+const researchSuffix = ":research"
+
+const researchPlanPrompt = `你是研究规划助手。把用户的研究问题拆分为最多 %d 个需要通过网络搜索回答的子问题，覆盖问题的各个方面。
+只输出 JSON 字符串数组，例如 ["子问题一", "子问题二"]，不要输出其他内容。`
+
+const researchEvaluatePrompt = `你是研究评估助手。根据研究问题、已检索的子问题和已收集的资料，判断资料是否足以写出全面、可靠的研究报告。
+只输出 JSON 对象：{"done": true 或 false, "questions": ["还需要补充检索的子问题，最多 %d 个"]}，不要输出其他内容。`
+
+const researchReportPrompt = `你是专业的研究分析师。根据提供的资料撰写一份结构清晰的长篇研究报告：
+使用 Markdown 标题组织内容，先给出结论摘要，再分节展开分析，最后总结尚不确定或资料不足的部分。
+只依据提供的资料陈述事实，不要编造数据。`
+
+// maxNoteLength bounds the crawled text kept for each source
+const maxNoteLength = 3000
+
+// researchBudget limits a deep research run
+type researchBudget struct {
+	MaxSteps         int
+	MaxQuestions     int
+	MaxTokens        int
+	MaxDuration      time.Duration
+	CrawlPerQuestion int
+}
+
+// loadResearchBudget reads the research budget, applying request overrides
+func loadResearchBudget(options *ResearchOptions) researchBudget {
+	budget := researchBudget{
+		MaxSteps:         getEnvInt("RESEARCH_MAX_STEPS", 3),
+		MaxQuestions:     getEnvInt("RESEARCH_MAX_QUESTIONS", 4),
+		MaxTokens:        getEnvInt("RESEARCH_MAX_TOKENS", 60000),
+		MaxDuration:      getEnvDuration("RESEARCH_TIMEOUT", 5*time.Minute),
+		CrawlPerQuestion: getEnvInt("RESEARCH_CRAWL_PER_QUESTION", 1),
+	}
+	if options != nil {
+		if options.MaxSteps > 0 {
+			budget.MaxSteps = options.MaxSteps
+		}
+		if options.MaxTokens > 0 {
+			budget.MaxTokens = options.MaxTokens
+		}
+		if options.MaxDuration > 0 {
+			budget.MaxDuration = time.Duration(options.MaxDuration) * time.Second
+		}
+	}
+	return budget
+}
+
+// researcher runs the plan, search and evaluate loop for one question
+type researcher struct {
+	session  *toolSession
+	model    string
+	budget   researchBudget
+	question string
+	tokens   int
+	asked    []string
+	// notes holds crawled text keyed by citation number
+	notes map[int]string
+}
+
+// handleResearch handles the deep research endpoint
+func handleResearch(c *gin.Context) {
+	req, apiKey, err := prepareRequest(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, errTypeInvalidRequest, err.Error())
+		return
+	}
+	runResearch(c, req, apiKey)
+}
+
+// runResearch gathers sources within the budget and writes a cited report
+func runResearch(c *gin.Context, req *ChatCompletionRequest, apiKey string) {
+	question := lastUserText(req.Messages)
+	if question == "" {
+		respondError(c, http.StatusBadRequest, errTypeInvalidRequest, "research requires a user message")
+		return
+	}
+
+	budget := loadResearchBudget(req.Options.Research)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), budget.MaxDuration)
+	defer cancel()
+
+	options := req.Options
+	options.Citations = true
+	session := newToolSession(ctx, apiKey, options)
+	session.conversation = question
+	if req.Stream {
+		setStreamHeaders(c, http.StatusOK)
+		session.onProgress = func(event stream.ProgressEvent) {
+			stream.WriteProgress(c.Writer, req.Model, event)
+		}
+	}
+
+	model := os.Getenv("RESEARCH_MODEL")
+	if model == "" {
+		model = req.Model
+	}
+	r := &researcher{
+		session:  session,
+		model:    model,
+		budget:   budget,
+		question: question,
+		notes:    make(map[int]string),
+	}
+	r.run()
+
+	// The report is written with the full request context, not the research deadline
+	session.ctx = c.Request.Context()
+	r.writeReport(c, req)
+}
+
+// run iterates planning, searching and evaluation until the budget is spent
+// or the gathered sources are judged sufficient
+func (r *researcher) run() {
+	questions, err := r.plan()
+	if err != nil {
+		log.Printf("Error planning research: %v", err)
+		questions = []string{r.question}
+	}
+	r.session.notify(stream.ProgressEvent{Type: stream.ProgressResearchPlan, Questions: questions})
+
+	for step := 1; step <= r.budget.MaxSteps && len(questions) > 0; step++ {
+		r.session.notify(stream.ProgressEvent{Type: stream.ProgressResearchStep, Step: step, Questions: questions})
+		r.search(questions)
+
+		if step == r.budget.MaxSteps {
+			break
+		}
+		if reason := r.exhausted(); reason != "" {
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressResearchEvaluate, Step: step, Message: reason})
+			break
+		}
+
+		done, next, err := r.evaluate()
+		if err != nil {
+			log.Printf("Error evaluating research: %v", err)
+			break
+		}
+		if done {
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressResearchEvaluate, Step: step, Message: "sources are sufficient"})
+			break
+		}
+		r.session.notify(stream.ProgressEvent{Type: stream.ProgressResearchEvaluate, Step: step, Questions: next})
+		questions = next
+	}
+}
+
+// exhausted returns why the research budget is used up, or an empty string
+func (r *researcher) exhausted() string {
+	if r.session.ctx.Err() != nil {
+		return "time budget exhausted"
+	}
+	if r.budget.MaxTokens > 0 && r.tokens >= r.budget.MaxTokens {
+		return "token budget exhausted"
+	}
+	return ""
+}
+
+// plan asks the model for the initial sub-questions
+func (r *researcher) plan() ([]string, error) {
+	content, err := r.complete(fmt.Sprintf(researchPlanPrompt, r.budget.MaxQuestions), r.question)
+	if err != nil {
+		return nil, err
+	}
+	questions := parseQueries(content)
+	if len(questions) > r.budget.MaxQuestions {
+		questions = questions[:r.budget.MaxQuestions]
+	}
+	return questions, nil
+}
+
+// evaluate asks the model whether the sources cover the question
+func (r *researcher) evaluate() (bool, []string, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "研究问题：%s\n\n已检索的子问题：\n", r.question)
+	for _, q := range r.asked {
+		fmt.Fprintf(&sb, "- %s\n", q)
+	}
+	sb.WriteString("\n已收集的资料：\n")
+	for i, result := range r.session.searchResults {
+		fmt.Fprintf(&sb, "[%d] %s：%s\n", i+1, result.Title, result.Snippet)
+	}
+
+	content, err := r.complete(fmt.Sprintf(researchEvaluatePrompt, r.budget.MaxQuestions), sb.String())
+	if err != nil {
+		return false, nil, err
+	}
+
+	var verdict struct {
+		Done      bool     `json:"done"`
+		Questions []string `json:"questions"`
+	}
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return false, nil, fmt.Errorf("invalid evaluation response: %s", content)
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &verdict); err != nil {
+		return false, nil, fmt.Errorf("error parsing evaluation response: %v", err)
+	}
+
+	// Skip questions that were already searched
+	var next []string
+	for _, q := range cleanQueries(verdict.Questions) {
+		if !containsString(r.asked, q) {
+			next = append(next, q)
+		}
+	}
+	if len(next) > r.budget.MaxQuestions {
+		next = next[:r.budget.MaxQuestions]
+	}
+	return verdict.Done || len(next) == 0, next, nil
+}
+
+// complete runs a planning completion and accounts for its tokens
+func (r *researcher) complete(system string, user string) (string, error) {
+	req := &ChatCompletionRequest{
+		Model: r.model,
+		Messages: []map[string]interface{}{
+			{"role": "system", "content": system},
+			{"role": "user", "content": user},
+		},
+	}
+	content, tokens, err := completeChat(r.session.ctx, req, r.session.apiKey)
+	r.tokens += tokens
+	return content, err
+}
+
+// search runs the sub-questions in parallel and reads the top new results
+func (r *researcher) search(questions []string) {
+	resultSets := make([][]units.SearchResult, len(questions))
+	var wg sync.WaitGroup
+	for i, q := range questions {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: q})
+			response, err := searchWithRewrite(r.session, q, units.SearchOptions{})
+			if err != nil {
+				r.session.notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: q, Queries: response.Queries, Error: err.Error()})
+				return
+			}
+			urls := make([]string, 0, len(response.Results))
+			for _, result := range response.Results {
+				urls = append(urls, result.Link)
+			}
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: q, Queries: response.Queries, ResultCount: len(response.Results), URLs: urls})
+			resultSets[i] = response.Results
+		}(i, q)
+	}
+	wg.Wait()
+	r.asked = append(r.asked, questions...)
+
+	// Record results in question order so numbering is deterministic
+	var toCrawl []units.SearchResult
+	for _, results := range resultSets {
+		before := len(r.session.searchResults)
+		r.session.addSearchResults(results)
+		added := r.session.searchResults[before:]
+		for i := 0; i < len(added) && i < r.budget.CrawlPerQuestion; i++ {
+			toCrawl = append(toCrawl, added[i])
+		}
+	}
+
+	var mu sync.Mutex
+	for _, result := range toCrawl {
+		wg.Add(1)
+		go func(result units.SearchResult) {
+			defer wg.Done()
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressCrawlStarted, URL: result.Link})
+			page, err := units.Crawl(result.Link, r.session.options.CrawlerService)
+			if err != nil {
+				r.session.notify(stream.ProgressEvent{Type: stream.ProgressCrawlFailed, URL: result.Link, Error: err.Error()})
+				return
+			}
+			r.session.notify(stream.ProgressEvent{Type: stream.ProgressCrawlCompleted, URL: result.Link})
+			r.session.addCrawlResult(page)
+			mu.Lock()
+			r.notes[r.session.sourceNumber(result.Link)] = units.TruncateText(page.Content, maxNoteLength)
+			mu.Unlock()
+		}(result)
+	}
+	wg.Wait()
+}
+
+// writeReport asks the model for the final cited report and sends it to the client
+func (r *researcher) writeReport(c *gin.Context, req *ChatCompletionRequest) {
+	session := r.session
+	session.notify(stream.ProgressEvent{Type: stream.ProgressResearchReport, ResultCount: len(session.searchResults)})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "研究问题：%s\n\n资料：\n\n", r.question)
+	for i, result := range session.searchResults {
+		fmt.Fprintf(&sb, "[%d] %s\nURL: %s\n", i+1, result.Title, result.Link)
+		if result.PublishedAt != "" {
+			fmt.Fprintf(&sb, "发布时间：%s\n", result.PublishedAt)
+		}
+		fmt.Fprintf(&sb, "摘要：%s\n", result.Snippet)
+		if note := r.notes[i+1]; note != "" {
+			fmt.Fprintf(&sb, "正文摘录：%s\n", note)
+		}
+		sb.WriteString("\n")
+	}
+	if len(session.searchResults) == 0 {
+		sb.WriteString("（没有检索到资料，请说明无法完成研究的原因）\n")
+	}
+
+	reportReq := &ChatCompletionRequest{
+		Model: req.Model,
+		Messages: []map[string]interface{}{
+			{"role": "system", "content": researchReportPrompt + "\n\n" + citationPrompt},
+			{"role": "user", "content": sb.String()},
+		},
+		MaxTokens: req.MaxTokens,
+		Stream:    req.Stream,
+	}
+
+	resp, err := forwardToOpenAI(session.ctx, reportReq, session.apiKey)
+	if err != nil {
+		log.Printf("Error requesting research report: %v", err)
+		if req.Stream {
+			writeStreamError(c, errTypeUpstream, err.Error())
+			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+		} else {
+			respondError(c, http.StatusBadGateway, errTypeUpstream, err.Error())
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := readUpstreamError(resp)
+		log.Printf("Upstream error writing research report: %s", apiErr.Message)
+		if req.Stream {
+			writeStreamError(c, apiErr.Type, apiErr.Message)
+			fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+		} else {
+			c.JSON(resp.StatusCode, ErrorResponse{Error: apiErr})
+		}
+		return
+	}
+
+	tracker := stream.NewCitationTracker(session.hasSource)
+	if !req.Stream {
+		var openaiResp ChatCompletionResponseWithSearchResults
+		if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
+			respondError(c, http.StatusBadGateway, errTypeUpstream, "error parsing OpenAI response")
+			return
+		}
+		if len(openaiResp.Choices) > 0 {
+			if content, ok := openaiResp.Choices[0].Message["content"].(string); ok {
+				openaiResp.Choices[0].Message["content"] = tracker.Filter(content) + tracker.Flush()
+			}
+		}
+		openaiResp.SearchResults = session.searchResults
+		openaiResp.CrawlResults = session.crawlResults
+		openaiResp.Citations = session.buildCitations(tracker.Used())
+		c.JSON(http.StatusOK, openaiResp)
+		return
+	}
+
+	processor := stream.NewProcessor(c.Writer)
+	processor.SetCitationTracker(tracker)
+	processor.ProcessStream(resp.Body)
+	if err := processor.Err(); err != nil {
+		writeStreamError(c, errTypeUpstream, err.Error())
+	}
+	if req.Options.SearchResultsPlacement != placementNone && len(session.searchResults) > 0 {
+		stream.WriteSearchResults(c.Writer, req.Model, session.searchResults)
+	}
+	if req.Options.SearchResultsPlacement != placementNone && len(session.crawlResults) > 0 {
+		stream.WriteCrawlResults(c.Writer, req.Model, session.crawlResults)
+	}
+	stream.WriteCitations(c.Writer, req.Model, session.buildCitations(tracker.Used()))
+	fmt.Fprintf(c.Writer, "data: [DONE]\n\n")
+}
+
+// lastUserText returns the text of the last user message
+func lastUserText(messages []map[string]interface{}) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] != "user" {
+			continue
+		}
+		switch content := messages[i]["content"].(type) {
+		case string:
+			return strings.TrimSpace(content)
+		case []interface{}:
+			var parts []string
+			for _, part := range content {
+				if p, ok := part.(map[string]interface{}); ok && p["type"] == "text" {
+					if text, ok := p["text"].(string); ok {
+						parts = append(parts, text)
+					}
+				}
+			}
+			return strings.TrimSpace(strings.Join(parts, "\n"))
+		}
+	}
+	return ""
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
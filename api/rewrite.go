@@ -0,0 +1,171 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/liyown/search4ai-go/units"
+)
+
+// rewritePrompt asks the rewrite model for focused keyword queries
+const rewritePrompt = `你是搜索查询优化助手。把用户的问题改写为 1 到 %d 个简洁、聚焦的搜索引擎关键词查询。
+如果问题包含多个子问题，为每个子问题生成一个查询；保持原问题的语言。
+只输出 JSON 字符串数组，例如 ["查询一", "查询二"]，不要输出其他内容。`
+
+// rewriteEnabled reports whether queries should be rewritten for this session
+func rewriteEnabled(session *toolSession) bool {
+	if os.Getenv("QUERY_REWRITE_MODEL") == "" {
+		return false
+	}
+	return session.options.QueryRewrite == nil || *session.options.QueryRewrite
+}
+
+// searchWithRewrite runs a search, first rewriting the query when enabled.
+// The response's Queries are the rewritten queries, which are nil when the
+// original query was searched as-is.
+func searchWithRewrite(session *toolSession, query string, opts units.SearchOptions) (units.SearchResponse, error) {
+	opts.Context = session.conversation
+	opts.APIKey = session.apiKey
+	if !rewriteEnabled(session) {
+		return units.SearchWithAnswer(query, opts)
+	}
+
+	queries, err := rewriteQuery(session, query)
+	if err != nil || len(queries) == 0 {
+		if err != nil {
+			log.Printf("Query rewrite failed, using the original query: %v", err)
+		}
+		return units.SearchWithAnswer(query, opts)
+	}
+
+	// Run the rewritten queries in parallel; their candidate pools are
+	// reranked once, after merging
+	queryOpts := opts
+	queryOpts.Unranked = true
+	responses := make([]units.SearchResponse, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q string) {
+			defer wg.Done()
+			responses[i], errs[i] = units.SearchWithAnswer(q, queryOpts)
+		}(i, q)
+	}
+	wg.Wait()
+
+	var firstErr error
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if succeeded == 0 {
+		return units.SearchResponse{Queries: queries}, firstErr
+	}
+
+	// Rank the merged pool against the original query before trimming; the
+	// interleaved pool is capped so it is no larger than a single search's
+	resultSets := make([][]units.SearchResult, len(responses))
+	var answers []string
+	for i, response := range responses {
+		resultSets[i] = response.Results
+		if response.Answer != "" {
+			answers = append(answers, response.Answer)
+		}
+	}
+	merged := mergeResults(resultSets, units.CandidateCount())
+	return units.SearchResponse{
+		Results: units.Rerank(merged, query, opts, units.MaxResults()),
+		Queries: queries,
+		Answer:  strings.Join(answers, "\n\n"),
+	}, nil
+}
+
+// rewriteQuery asks the configured model to turn a query into focused search queries
+func rewriteQuery(session *toolSession, query string) ([]string, error) {
+	maxQueries := getEnvInt("QUERY_REWRITE_MAX_QUERIES", 3)
+	req := &ChatCompletionRequest{
+		Model: os.Getenv("QUERY_REWRITE_MODEL"),
+		Messages: []map[string]interface{}{
+			{"role": "system", "content": fmt.Sprintf(rewritePrompt, maxQueries)},
+			{"role": "user", "content": query},
+		},
+		MaxTokens: 200,
+	}
+
+	content, _, err := completeChat(session.ctx, req, session.apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	queries := parseQueries(content)
+	if len(queries) > maxQueries {
+		queries = queries[:maxQueries]
+	}
+	return queries, nil
+}
+
+// parseQueries extracts queries from a JSON array, tolerating code fences
+// and falling back to one query per line
+func parseQueries(content string) []string {
+	content = strings.TrimSpace(content)
+	if start, end := strings.Index(content, "["), strings.LastIndex(content, "]"); start >= 0 && end > start {
+		var queries []string
+		if err := json.Unmarshal([]byte(content[start:end+1]), &queries); err == nil {
+			return cleanQueries(queries)
+		}
+	}
+	return cleanQueries(strings.Split(content, "\n"))
+}
+
+// cleanQueries trims list markers and drops empty or duplicate queries
+func cleanQueries(lines []string) []string {
+	var queries []string
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		q := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*0123456789.、`\""))
+		q = strings.Trim(q, "`\"")
+		if q == "" || seen[q] {
+			continue
+		}
+		seen[q] = true
+		queries = append(queries, q)
+	}
+	return queries
+}
+
+// mergeResults interleaves result sets so every query contributes its top
+// results, skipping duplicate links and stopping at limit
+func mergeResults(resultSets [][]units.SearchResult, limit int) []units.SearchResult {
+	var merged []units.SearchResult
+	seen := make(map[string]bool)
+	for i := 0; ; i++ {
+		added := false
+		for _, results := range resultSets {
+			if i >= len(results) {
+				continue
+			}
+			added = true
+			key := sourceKey(results[i].Link)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, results[i])
+			if limit > 0 && len(merged) >= limit {
+				return merged
+			}
+		}
+		if !added {
+			return merged
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/liyown/search4ai-go/units"
 )
 
 // setupCORS adds CORS middleware to the Gin engine
@@ -57,36 +57,45 @@ func prepareRequest(c *gin.Context) (*ChatCompletionRequest, string, error) {
 	if err := json.Unmarshal(body, &req); err != nil {
 		return nil, "", fmt.Errorf("error parsing request body: %v", err)
 	}
-
-	// Add tools if not present
+	// A model suffix such as "gpt-4o:research" switches to deep research; it is
+	// removed first so the model name matches its system prompt template
+	if strings.HasSuffix(req.Model, researchSuffix) {
+		req.Model = strings.TrimSuffix(req.Model, researchSuffix)
+		req.research = true
+	}
+	// Add tools if not present; the system prompt describes the tools offered
 	if req.Tools == nil {
 		req.Tools = buildTools(nil)
 	}
+	// Recursive tool rounds re-enter here with a body that no longer carries the
+	// proxy options, and whose messages were already prepared
+	if options, ok := c.Get("proxyOptions"); ok {
+		req.Options = options.(ProxyOptions)
+	} else {
+		if err := json.Unmarshal(body, &req.Options); err != nil {
+			return nil, "", fmt.Errorf("error parsing request options: %v", err)
+		}
+		switch req.Options.SearchResultsPlacement {
+		case "":
+			req.Options.SearchResultsPlacement = placementDedicated
+		case placementDedicated, placementFinal, placementNone:
+		default:
+			return nil, "", fmt.Errorf("invalid search_results_placement: %s", req.Options.SearchResultsPlacement)
+		}
+		if req.Options.CrawlerService != "" && !units.IsCrawlerService(req.Options.CrawlerService) {
+			return nil, "", fmt.Errorf("invalid crawler_service: %s", req.Options.CrawlerService)
+		}
+		c.Set("proxyOptions", req.Options)
 
-	return &req, apiKey, nil
-}
-
-func forwardToOpenAI(req *ChatCompletionRequest, apiKey string) (*http.Response, error) {
-	apiBase := os.Getenv("APIBASE")
-	if apiBase == "" {
-		apiBase = "https://api.openai.com"
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("error preparing request: %v", err)
-	}
-
-	client := &http.Client{}
-	openaiReq, err := http.NewRequest("POST", apiBase+"/v1/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("error creating OpenAI request: %v", err)
+		if req.Options.SystemPrompt == nil || *req.Options.SystemPrompt {
+			injectSystemPrompt(&req, apiKey, requestLocale(req.Options, c.GetHeader("Accept-Language")))
+		}
+		if req.Options.Citations {
+			addSystemPrompt(&req, citationPrompt)
+		}
 	}
 
-	openaiReq.Header.Set("Content-Type", "application/json")
-	openaiReq.Header.Set("Authorization", "Bearer "+apiKey)
-
-	return client.Do(openaiReq)
+	return &req, apiKey, nil
 }
 
 // StartServer initializes and starts the HTTP server
@@ -115,6 +124,9 @@ func StartServer() error {
 	// Chat completions endpoint
 	r.POST("/v1/chat/completions", handleChatCompletions)
 
+	// Deep research endpoint
+	r.POST("/v1/research", handleResearch)
+
 	// Get port from environment
 	port := os.Getenv("PORT")
 	if port == "" {
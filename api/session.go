@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/liyown/search4ai-go/stream"
+	"github.com/liyown/search4ai-go/units"
+)
+
+// Placements for search results in streaming responses
+const (
+	placementDedicated = "dedicated"
+	placementFinal     = "final"
+	placementNone      = "none"
+)
+
+// toolSession carries per-request state across tool rounds
+type toolSession struct {
+	ctx     context.Context
+	apiKey  string
+	options ProxyOptions
+	// conversation is the latest user message, used as reranking context
+	conversation  string
+	onProgress    func(stream.ProgressEvent)
+	progressMu    sync.Mutex
+	searchResults []units.SearchResult
+	// sources maps a normalised link to its 1-based citation number
+	sources map[string]int
+	// sent counts the search results already emitted to a streaming client
+	sent int
+	// images are image_search results waiting to be shown to the model
+	images []units.ImageResult
+	// crawlResults are the pages crawled so far; crawlSent counts those emitted to a streaming client
+	crawlMu      sync.Mutex
+	crawlResults []units.CrawlResult
+	crawlSent    int
+}
+
+// newToolSession creates an empty tool session
+func newToolSession(ctx context.Context, apiKey string, options ProxyOptions) *toolSession {
+	return &toolSession{
+		ctx:     ctx,
+		apiKey:  apiKey,
+		options: options,
+		sources: make(map[string]int),
+	}
+}
+
+// notify reports a progress event if a listener is registered.
+// It is safe to call from concurrent searches.
+func (s *toolSession) notify(event stream.ProgressEvent) {
+	if s.onProgress != nil {
+		s.progressMu.Lock()
+		defer s.progressMu.Unlock()
+		s.onProgress(event)
+	}
+}
+
+// addSearchResults records search results, skipping links seen in earlier rounds
+func (s *toolSession) addSearchResults(results []units.SearchResult) {
+	for _, result := range results {
+		key := sourceKey(result.Link)
+		if key == "" || s.sources[key] > 0 {
+			continue
+		}
+		s.searchResults = append(s.searchResults, result)
+		s.sources[key] = len(s.searchResults)
+	}
+}
+
+// sourceNumber returns the citation number of a link, or 0 if it is unknown
+func (s *toolSession) sourceNumber(link string) int {
+	return s.sources[sourceKey(link)]
+}
+
+// hasSource reports whether n refers to a recorded search result
+func (s *toolSession) hasSource(n int) bool {
+	return n >= 1 && n <= len(s.searchResults)
+}
+
+// buildCitations maps citation numbers to their sources
+func (s *toolSession) buildCitations(used []int) []stream.Citation {
+	citations := make([]stream.Citation, 0, len(used))
+	for _, n := range used {
+		if !s.hasSource(n) {
+			continue
+		}
+		result := s.searchResults[n-1]
+		citations = append(citations, stream.Citation{
+			Index:       n,
+			Title:       result.Title,
+			URL:         result.Link,
+			Snippet:     result.Snippet,
+			Source:      result.Source,
+			PublishedAt: result.PublishedAt,
+			Favicon:     result.Favicon,
+		})
+	}
+	return citations
+}
+
+// sourceKey normalises a link for deduplication
+func sourceKey(link string) string {
+	return strings.TrimSuffix(link, "/")
+}
+
+// unsentSearchResults returns the results not yet emitted and marks them as sent
+func (s *toolSession) unsentSearchResults() []units.SearchResult {
+	results := s.searchResults[s.sent:]
+	s.sent = len(s.searchResults)
+	return results
+}
+
+// addCrawlResult records a crawled page. It is safe to call from concurrent crawls.
+func (s *toolSession) addCrawlResult(result *units.CrawlResult) {
+	s.crawlMu.Lock()
+	defer s.crawlMu.Unlock()
+	s.crawlResults = append(s.crawlResults, *result)
+}
+
+// unsentCrawlResults returns the crawled pages not yet emitted and marks them as sent
+func (s *toolSession) unsentCrawlResults() []units.CrawlResult {
+	s.crawlMu.Lock()
+	defer s.crawlMu.Unlock()
+	results := s.crawlResults[s.crawlSent:]
+	s.crawlSent = len(s.crawlResults)
+	return results
+}
+
+// imageInputLimit returns how many images from each image search are shown to the model
+func (s *toolSession) imageInputLimit() int {
+	if s.options.ImageInputs != nil {
+		return *s.options.ImageInputs
+	}
+	return getEnvInt("IMAGE_INPUTS", 0)
+}
+
+// addImageInputs queues the top images of a search to be shown to the model
+func (s *toolSession) addImageInputs(images []units.ImageResult) {
+	limit := s.imageInputLimit()
+	for _, image := range images {
+		if limit <= 0 {
+			break
+		}
+		if !strings.HasPrefix(image.ImageURL, "http://") && !strings.HasPrefix(image.ImageURL, "https://") {
+			continue
+		}
+		s.images = append(s.images, image)
+		limit--
+	}
+}
+
+// imageMessage returns a user message carrying the queued images as image_url
+// content parts, since tool messages cannot contain images, and clears the queue
+func (s *toolSession) imageMessage() map[string]interface{} {
+	if len(s.images) == 0 {
+		return nil
+	}
+
+	parts := []map[string]interface{}{
+		{"type": "text", "text": "以下是 image_search 工具找到的图片，请结合图片内容回答："},
+	}
+	for _, image := range s.images {
+		parts = append(parts, map[string]interface{}{
+			"type": "image_url",
+			"image_url": map[string]interface{}{
+				"url":    image.ImageURL,
+				"detail": "low",
+			},
+		})
+	}
+	s.images = nil
+
+	return map[string]interface{}{
+		"role":    "user",
+		"content": parts,
+	}
+}
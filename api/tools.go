@@ -4,36 +4,58 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/liyown/search4ai-go/stream"
 	"github.com/liyown/search4ai-go/units"
 )
 
-func executeToolCalls(toolCalls []interface{}) ([]map[string]interface{}, error) {
+// executeToolCalls runs the tool calls in order, recording results in the session.
+// Every call gets a tool message, since upstream APIs reject a tool_call_id without
+// one; failed calls report their error to the model. It fails only when no call succeeded.
+func executeToolCalls(toolCalls []interface{}, session *toolSession) ([]map[string]interface{}, error) {
 	var toolResults []map[string]interface{}
+	var lastErr error
+	succeeded := 0
 	for _, tc := range toolCalls {
 		toolCall, ok := tc.(map[string]interface{})
 		if !ok {
 			continue
 		}
 
-		result, err := executeToolCall(toolCall)
+		var name interface{}
+		if function, ok := toolCall["function"].(map[string]interface{}); ok {
+			name = function["name"]
+		}
+
+		result, err := executeToolCall(toolCall, session)
 		if err != nil {
 			log.Printf("Error executing tool call: %v", err)
-			continue
+			lastErr = err
+			result = fmt.Sprintf("工具调用失败：%v", err)
+		} else {
+			succeeded++
 		}
 
 		toolResults = append(toolResults, map[string]interface{}{
 			"tool_call_id": toolCall["id"],
 			"role":         "tool",
-			"name":         toolCall["function"].(map[string]interface{})["name"],
+			"name":         name,
 			"content":      result,
 		})
 	}
+
+	if succeeded == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	if message := session.imageMessage(); message != nil {
+		toolResults = append(toolResults, message)
+	}
 	return toolResults, nil
 }
 
 // executeToolCall executes a tool call and returns the result
-func executeToolCall(toolCall map[string]interface{}) (string, error) {
+func executeToolCall(toolCall map[string]interface{}, session *toolSession) (string, error) {
 	function, ok := toolCall["function"].(map[string]interface{})
 	if !ok {
 		return "", fmt.Errorf("invalid tool call format")
@@ -54,26 +76,433 @@ func executeToolCall(toolCall map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("error parsing arguments: %v", err)
 	}
 
+	id, _ := toolCall["id"].(string)
+	notify := func(event stream.ProgressEvent) {
+		event.ToolCallID = id
+		session.notify(event)
+	}
+
 	switch name {
-	case "search":
+	case "search", "news_search":
+		query, ok := args["query"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid search query")
+		}
+
+		opts, err := searchOptions(args)
+		if err != nil {
+			return "", err
+		}
+
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: query})
+		var response units.SearchResponse
+		if name == "news_search" {
+			// News queries name specific events, so they are searched as given
+			response.Results, err = units.NewsResults(query, opts)
+		} else {
+			response, err = searchWithRewrite(session, query, opts)
+		}
+		results, queries := response.Results, response.Queries
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: query, Queries: queries, Error: err.Error()})
+			return "", err
+		}
+
+		urls := make([]string, 0, len(results))
+		for _, result := range results {
+			urls = append(urls, result.Link)
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: query, Queries: queries, ResultCount: len(results), URLs: urls})
+		session.addSearchResults(results)
+
+		if session.options.Citations {
+			return formatNumberedResults(response, session), nil
+		}
+
+		jsonData, err := json.Marshal(toolSearchResponse{Results: compactResults(results), Queries: queries, Answer: response.Answer})
+		if err != nil {
+			return "", fmt.Errorf("error encoding search results: %v", err)
+		}
+		return string(jsonData), nil
+
+	case "image_search":
+		query, ok := args["query"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid search query")
+		}
+
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: query})
+		images, err := units.ImageResults(query)
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: query, Error: err.Error()})
+			return "", err
+		}
+
+		urls := make([]string, 0, len(images))
+		for _, image := range images {
+			urls = append(urls, image.ImageURL)
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: query, ResultCount: len(images), URLs: urls})
+		session.addImageInputs(images)
+
+		jsonData, err := json.Marshal(map[string]interface{}{"images": images})
+		if err != nil {
+			return "", fmt.Errorf("error encoding image results: %v", err)
+		}
+		return string(jsonData), nil
+
+	case "paper_search":
+		query, ok := args["query"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid search query")
+		}
+
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: query})
+		papers, err := units.PaperResults(query)
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: query, Error: err.Error()})
+			return "", err
+		}
+
+		urls := make([]string, 0, len(papers))
+		for _, paper := range papers {
+			urls = append(urls, paper.URL)
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: query, ResultCount: len(papers), URLs: urls})
+		session.addSearchResults(paperSearchResults(papers))
+
+		jsonData, err := json.Marshal(map[string]interface{}{"papers": compactPapers(papers, session)})
+		if err != nil {
+			return "", fmt.Errorf("error encoding paper results: %v", err)
+		}
+		return string(jsonData), nil
+
+	case "wikipedia":
+		query, ok := args["query"].(string)
+		if !ok {
+			return "", fmt.Errorf("invalid wikipedia query")
+		}
+		language, _ := args["language"].(string)
+
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: query})
+		article, err := units.WikipediaLookup(query, language)
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: query, Error: err.Error()})
+			return "", err
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: query, ResultCount: 1, URLs: []string{article.URL}})
+		session.addSearchResults([]units.SearchResult{{
+			Title:   article.Title,
+			Link:    article.URL,
+			Snippet: units.TruncateText(article.Summary, 300),
+			Source:  "Wikipedia",
+		}})
+
+		content := article.Markdown(stringSlice(args["sections"]))
+		if session.options.Citations {
+			content = fmt.Sprintf("[%d] %s", session.sourceNumber(article.URL), content)
+		}
+		return content, nil
+
+	case "code_search":
 		query, ok := args["query"].(string)
 		if !ok {
 			return "", fmt.Errorf("invalid search query")
 		}
-		return units.Search(query)
+		source, _ := args["source"].(string)
+		if source == "" {
+			source = units.CodeSourceStackExchange
+		}
+		language, _ := args["language"].(string)
+		repository, _ := args["repository"].(string)
+
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchStarted, Query: query})
+		results, err := units.CodeResults(query, source, units.CodeOptions{Language: language, Repository: repository})
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressSearchFailed, Query: query, Error: err.Error()})
+			return "", err
+		}
+
+		urls := make([]string, 0, len(results))
+		searchResults := make([]units.SearchResult, 0, len(results))
+		for _, result := range results {
+			urls = append(urls, result.URL)
+			searchResults = append(searchResults, units.SearchResult{
+				Title:    result.Title,
+				Link:     result.URL,
+				Snippet:  units.TruncateText(result.Snippet, 300),
+				Source:   result.Repository,
+				Provider: result.Source,
+			})
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressSearchCompleted, Query: query, ResultCount: len(results), URLs: urls})
+		session.addSearchResults(searchResults)
+
+		views := make([]toolCodeResult, 0, len(results))
+		for _, result := range results {
+			view := toolCodeResult{CodeResult: result}
+			if session.options.Citations {
+				view.Index = session.sourceNumber(result.URL)
+			}
+			views = append(views, view)
+		}
+		jsonData, err := json.Marshal(map[string]interface{}{"results": views})
+		if err != nil {
+			return "", fmt.Errorf("error encoding code results: %v", err)
+		}
+		return string(jsonData), nil
 
 	case "crawler":
 		url, ok := args["url"].(string)
 		if !ok {
 			return "", fmt.Errorf("invalid crawler url")
 		}
-		return units.Crawler(url)
+
+		notify(stream.ProgressEvent{Type: stream.ProgressCrawlStarted, URL: url})
+		result, err := units.Crawl(url, session.options.CrawlerService)
+		if err != nil {
+			notify(stream.ProgressEvent{Type: stream.ProgressCrawlFailed, URL: url, Error: err.Error()})
+			return "", err
+		}
+		notify(stream.ProgressEvent{Type: stream.ProgressCrawlCompleted, URL: url})
+		session.addCrawlResult(result)
+		return result.Markdown(), nil
 
 	default:
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 }
 
+// searchOptions reads the time range and domain filters shared by the search tools
+func searchOptions(args map[string]interface{}) (units.SearchOptions, error) {
+	period, _ := args["time_range"].(string)
+	startDate, _ := args["start_date"].(string)
+	endDate, _ := args["end_date"].(string)
+	timeRange, err := units.ParseTimeRange(period, startDate, endDate)
+	if err != nil {
+		return units.SearchOptions{}, err
+	}
+
+	return units.SearchOptions{
+		TimeRange:      timeRange,
+		IncludeDomains: stringSlice(args["include_domains"]),
+		ExcludeDomains: stringSlice(args["exclude_domains"]),
+	}, nil
+}
+
+// stringSlice converts a JSON array argument to a string slice, ignoring non-string items
+func stringSlice(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	var strs []string
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+// toolSearchResult is the compact view of a search result given to the model.
+// Display-only metadata such as thumbnails is left out to save tokens.
+type toolSearchResult struct {
+	Title       string `json:"title"`
+	Link        string `json:"link"`
+	Snippet     string `json:"snippet"`
+	Source      string `json:"source,omitempty"`
+	PublishedAt string `json:"published_at,omitempty"`
+}
+
+// toolSearchResponse is the search tool output given to the model
+type toolSearchResponse struct {
+	Results []toolSearchResult `json:"results"`
+	Queries []string           `json:"queries,omitempty"`
+	Answer  string             `json:"answer,omitempty"`
+}
+
+// compactResults converts search results to their model-facing view
+func compactResults(results []units.SearchResult) []toolSearchResult {
+	compact := make([]toolSearchResult, 0, len(results))
+	for _, result := range results {
+		compact = append(compact, toolSearchResult{
+			Title:       result.Title,
+			Link:        result.Link,
+			Snippet:     result.Snippet,
+			Source:      result.Source,
+			PublishedAt: result.PublishedAt,
+		})
+	}
+	return compact
+}
+
+// paperAbstractLength bounds the abstract shown to the model for each paper
+const paperAbstractLength = 600
+
+// toolPaper is the model-facing view of a paper
+type toolPaper struct {
+	// Index is the citation number, set in citations mode
+	Index    int      `json:"index,omitempty"`
+	Title    string   `json:"title"`
+	Authors  []string `json:"authors,omitempty"`
+	Year     int      `json:"year,omitempty"`
+	Venue    string   `json:"venue,omitempty"`
+	Abstract string   `json:"abstract,omitempty"`
+	DOI      string   `json:"doi,omitempty"`
+	URL      string   `json:"url,omitempty"`
+	PDFURL   string   `json:"pdf_url,omitempty"`
+}
+
+// compactPapers converts papers to their model-facing view, shortening abstracts and author lists
+func compactPapers(papers []units.Paper, session *toolSession) []toolPaper {
+	compact := make([]toolPaper, 0, len(papers))
+	for _, paper := range papers {
+		authors := paper.Authors
+		if len(authors) > 5 {
+			authors = append(authors[:5:5], "et al.")
+		}
+		view := toolPaper{
+			Title:    paper.Title,
+			Authors:  authors,
+			Year:     paper.Year,
+			Venue:    paper.Venue,
+			Abstract: units.TruncateText(paper.Abstract, paperAbstractLength),
+			DOI:      paper.DOI,
+			URL:      paper.URL,
+			PDFURL:   paper.PDFURL,
+		}
+		if session.options.Citations {
+			view.Index = session.sourceNumber(paperLink(paper))
+		}
+		compact = append(compact, view)
+	}
+	return compact
+}
+
+// toolCodeResult is the model-facing view of a code search result
+type toolCodeResult struct {
+	// Index is the citation number, set in citations mode
+	Index int `json:"index,omitempty"`
+	units.CodeResult
+}
+
+// paperSearchResults converts papers to search results so they are returned and cited like web sources
+func paperSearchResults(papers []units.Paper) []units.SearchResult {
+	results := make([]units.SearchResult, 0, len(papers))
+	for _, paper := range papers {
+		result := units.SearchResult{
+			Title:    paper.Title,
+			Link:     paperLink(paper),
+			Snippet:  units.TruncateText(paper.Abstract, paperAbstractLength),
+			Source:   paper.Venue,
+			Provider: paper.Provider,
+		}
+		if paper.Year > 0 {
+			result.PublishedAt = fmt.Sprintf("%d", paper.Year)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// paperLink returns the link identifying a paper, preferring its DOI
+func paperLink(paper units.Paper) string {
+	if paper.DOI != "" {
+		return "https://doi.org/" + paper.DOI
+	}
+	if paper.URL != "" {
+		return paper.URL
+	}
+	return paper.PDFURL
+}
+
+// formatNumberedResults renders search results with their citation numbers
+func formatNumberedResults(response units.SearchResponse, session *toolSession) string {
+	results := response.Results
+	var sb strings.Builder
+	if len(response.Queries) > 0 {
+		fmt.Fprintf(&sb, "搜索查询：%s\n\n", strings.Join(response.Queries, "；"))
+	}
+	if response.Answer != "" {
+		fmt.Fprintf(&sb, "搜索服务生成的摘要（仅供参考，引用时请标注下方来源）：%s\n\n", response.Answer)
+	}
+	for _, result := range results {
+		// Results without a recorded link cannot be cited, so they get no number
+		if n := session.sourceNumber(result.Link); n > 0 {
+			fmt.Fprintf(&sb, "[%d] %s\nURL: %s\n", n, result.Title, result.Link)
+		} else {
+			fmt.Fprintf(&sb, "%s\n", result.Title)
+		}
+		if result.Source != "" {
+			fmt.Fprintf(&sb, "来源：%s\n", result.Source)
+		}
+		if result.PublishedAt != "" {
+			fmt.Fprintf(&sb, "发布时间：%s\n", result.PublishedAt)
+		}
+		fmt.Fprintf(&sb, "%s\n\n", result.Snippet)
+	}
+	if len(results) == 0 {
+		return "没有找到相关结果"
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// searchFilterProperties returns the time range and domain parameters shared by the search tools
+func searchFilterProperties() map[string]interface{} {
+	return map[string]interface{}{
+		"time_range": map[string]interface{}{
+			"type":        "string",
+			"enum":        []string{"day", "week", "month", "year", "custom"},
+			"description": "按发布时间过滤结果。查询最新动态（如“今天”“本周”的新闻）时设置为 day、week 等；需要指定日期区间时设置为 custom 并提供 start_date 和 end_date。不需要时间限制时省略。",
+		},
+		"start_date": map[string]interface{}{
+			"type":        "string",
+			"description": "time_range 为 custom 时的开始日期，格式 YYYY-MM-DD。",
+		},
+		"end_date": map[string]interface{}{
+			"type":        "string",
+			"description": "time_range 为 custom 时的结束日期（包含当天），格式 YYYY-MM-DD。",
+		},
+		"include_domains": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "只返回这些域名（含子域名）的结果，例如 [\"docs.python.org\"]。需要查阅官方文档或特定网站时使用。",
+		},
+		"exclude_domains": map[string]interface{}{
+			"type":        "array",
+			"items":       map[string]interface{}{"type": "string"},
+			"description": "排除这些域名（含子域名）的结果。",
+		},
+	}
+}
+
+// searchToolParameters builds the parameters schema of a search tool
+func searchToolParameters(queryDescription string) map[string]interface{} {
+	properties := searchFilterProperties()
+	properties["query"] = map[string]interface{}{
+		"type":        "string",
+		"description": queryDescription,
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"query"},
+	}
+}
+
+// toolNames returns the function names of the tools in a request
+func toolNames(tools []map[string]interface{}) map[string]bool {
+	names := make(map[string]bool)
+	for _, tool := range tools {
+		function, _ := tool["function"].(map[string]interface{})
+		if name, ok := function["name"].(string); ok {
+			names[name] = true
+		}
+	}
+	return names
+}
+
 // buildTools creates the tools configuration
 func buildTools(enabledTools map[string]bool) []map[string]interface{} {
 	tools := []map[string]interface{}{
@@ -82,12 +511,101 @@ func buildTools(enabledTools map[string]bool) []map[string]interface{} {
 			"function": map[string]interface{}{
 				"name":        "search",
 				"description": "搜索互联网获取实时信息。当你需要查找当前信息时使用此功能，例如日期、天气、新闻，或者可能不在你训练数据中的事实。搜索结果将包含相关网页的标题、链接和摘要。",
+				"parameters":  searchToolParameters("搜索查询。应该具体且聚焦于所需信息。使用可能出现在相关结果中的关键词和短语。"),
+			},
+		},
+		{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "news_search",
+				"description": "搜索新闻报道，结果按发布时间从新到旧排列，包含标题、媒体来源、发布时间和链接。当用户询问突发事件、最新进展或近期报道时优先使用此功能，而不是通用搜索。",
+				"parameters":  searchToolParameters("新闻搜索查询。使用事件、人物、机构等关键词，不需要包含“新闻”“最新”等词。"),
+			},
+		},
+		{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "image_search",
+				"description": "搜索网络图片，返回图片地址、缩略图、尺寸和所在网页。当用户想查看某样东西的外观、需要图片素材，或需要根据图片回答问题时使用此功能。",
+				"parameters": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]interface{}{
+							"type":        "string",
+							"description": "图片搜索查询，描述要查找的图片内容。",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+		{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "paper_search",
+				"description": "在 arXiv、Semantic Scholar 和 Crossref 中搜索学术论文，返回标题、作者、年份、期刊/会议、摘要、DOI 和 PDF 链接。当用户询问论文、研究成果或学术文献时使用此功能，而不是通用搜索。需要阅读全文时，可将 pdf_url 传给 crawler 工具。",
 				"parameters": map[string]interface{}{
 					"type": "object",
 					"properties": map[string]interface{}{
 						"query": map[string]interface{}{
 							"type":        "string",
-							"description": "搜索查询。应该具体且聚焦于所需信息。使用可能出现在相关结果中的关键词和短语。",
+							"description": "论文搜索查询，使用英文关键词、论文标题或作者名效果最好。",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+		{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "wikipedia",
+				"description": "查询维基百科条目，返回条目摘要及章节列表，或指定章节的内容（Markdown 格式）。查询人物、地点、组织、概念、历史事件等百科类事实时优先使用此功能，比搜索后再抓取网页更快。",
+				"parameters": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]interface{}{
+							"type":        "string",
+							"description": "条目名称或关键词。",
+						},
+						"language": map[string]interface{}{
+							"type":        "string",
+							"description": "维基百科语言代码，例如 en、zh、ja。省略时使用服务器默认语言。",
+						},
+						"sections": map[string]interface{}{
+							"type":        "array",
+							"items":       map[string]interface{}{"type": "string"},
+							"description": "要读取的章节标题，取自上一次查询返回的章节列表。省略时返回条目摘要。",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		},
+		{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "code_search",
+				"description": "搜索代码与编程问答：GitHub 代码、Issue/PR、仓库，以及 Stack Overflow 上有采纳答案的问题。回答编程问题、查找库的用法示例、排查报错或寻找开源项目时使用此功能。",
+				"parameters": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]interface{}{
+							"type":        "string",
+							"description": "搜索查询，例如报错信息、函数名或功能描述。",
+						},
+						"source": map[string]interface{}{
+							"type":        "string",
+							"enum":        []string{units.CodeSourceStackExchange, units.CodeSourceGitHubCode, units.CodeSourceGitHubIssues, units.CodeSourceGitHubRepos},
+							"description": "搜索来源：stackexchange（问答，默认）、github_code（代码）、github_issues（Issue 和 PR）、github_repos（仓库）。",
+						},
+						"language": map[string]interface{}{
+							"type":        "string",
+							"description": "编程语言，例如 go、python。用于过滤 GitHub 结果，在 Stack Exchange 中作为标签。",
+						},
+						"repository": map[string]interface{}{
+							"type":        "string",
+							"description": "将 GitHub 搜索限定在某个仓库，格式 owner/name。",
 						},
 					},
 					"required": []string{"query"},
@@ -113,13 +631,13 @@ func buildTools(enabledTools map[string]bool) []map[string]interface{} {
 		},
 	}
 
-	if enabledTools == nil {
-		return tools
-	}
-
 	var filteredTools []map[string]interface{}
 	for _, tool := range tools {
 		name := tool["function"].(map[string]interface{})["name"].(string)
+		// Only advertise image search when the configured service can answer it
+		if name == "image_search" && !units.SupportsImages() {
+			continue
+		}
 		if enabled, exists := enabledTools[name]; !exists || enabled {
 			filteredTools = append(filteredTools, tool)
 		}
@@ -1,12 +1,56 @@
 package api
 
+import (
+	"github.com/liyown/search4ai-go/stream"
+	"github.com/liyown/search4ai-go/units"
+)
+
 type ChatCompletionRequest struct {
 	Model      string                   `json:"model"`
 	Messages   []map[string]interface{} `json:"messages"`
-	MaxTokens  int                      `json:"max_tokens"`
+	MaxTokens  int                      `json:"max_tokens,omitempty"`
 	Tools      []map[string]interface{} `json:"tools,omitempty"`
 	ToolChoice string                   `json:"tool_choice,omitempty"`
 	Stream     bool                     `json:"stream"`
+
+	// Options holds proxy-specific settings that are not forwarded upstream
+	Options ProxyOptions `json:"-"`
+	// research is set when the model name carried researchSuffix, which
+	// prepareRequest removes
+	research bool
+}
+
+// ProxyOptions holds request options understood by the proxy itself
+type ProxyOptions struct {
+	// ToolProgress enables tool_progress chunks while tools run in streaming mode
+	ToolProgress bool `json:"tool_progress"`
+	// SearchResultsPlacement selects where streamed search and crawl results are sent:
+	// "dedicated" (default) after each tool round, "final" before [DONE], or "none"
+	SearchResultsPlacement string `json:"search_results_placement"`
+	// Citations numbers search results for the model and returns a citations array
+	Citations bool `json:"citations"`
+	// SystemPrompt overrides whether the configured system prompt is injected
+	SystemPrompt *bool `json:"system_prompt"`
+	// Locale is passed to the system prompt template, defaulting to Accept-Language
+	Locale string `json:"locale"`
+	// QueryRewrite overrides whether search queries are rewritten before searching
+	QueryRewrite *bool `json:"query_rewrite"`
+	// ImageInputs overrides how many image_search results are shown to the model
+	// as image_url content parts, defaulting to IMAGE_INPUTS
+	ImageInputs *int `json:"image_inputs"`
+	// CrawlerService selects the crawler service for this request, defaulting to CRAWLER_SERVICE
+	CrawlerService string `json:"crawler_service"`
+	// Research overrides the deep research budget
+	Research *ResearchOptions `json:"research"`
+}
+
+// ResearchOptions limits a deep research run
+type ResearchOptions struct {
+	MaxSteps int `json:"max_steps"`
+	// MaxTokens bounds the tokens spent on planning, not on the final report
+	MaxTokens int `json:"max_tokens"`
+	// MaxDuration bounds the research phase in seconds
+	MaxDuration int `json:"max_duration"`
 }
 
 // ChatCompletionResponse represents the response structure from OpenAI
@@ -20,10 +64,20 @@ type ChatCompletionResponse struct {
 		Message      map[string]interface{} `json:"message"`
 		FinishReason string                 `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Usage represents token usage reported by OpenAI
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 type ChatCompletionResponseWithSearchResults struct {
 	ChatCompletionResponse
-	SearchResults []map[string]interface{} `json:"search_results"`
+	SearchResults []units.SearchResult `json:"search_results"`
+	CrawlResults  []units.CrawlResult  `json:"crawl_results,omitempty"`
+	Citations     []stream.Citation    `json:"citations,omitempty"`
 }
 
 // ToolCall represents a tool call from OpenAI
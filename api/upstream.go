@@ -0,0 +1,222 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/liyown/search4ai-go/units"
+)
+
+// upstreamConfig holds the retry and timeout settings for model API requests
+type upstreamConfig struct {
+	MaxRetries     int
+	BaseDelay      time.Duration
+	MaxDelay       time.Duration
+	ConnectTimeout time.Duration
+	HeaderTimeout  time.Duration
+}
+
+var (
+	upstreamOnce         sync.Once
+	upstreamCfg          upstreamConfig
+	upstreamClient       *http.Client
+	upstreamStreamClient *http.Client
+)
+
+// getUpstream returns the shared upstream client for a request and the
+// configuration. Both are built lazily so that values from the .env file are
+// picked up. Only streaming requests get the response header timeout: a
+// non-streaming response sends its headers after the whole completion.
+func getUpstream(stream bool) (*http.Client, upstreamConfig) {
+	upstreamOnce.Do(func() {
+		upstreamCfg = upstreamConfig{
+			MaxRetries:     getEnvInt("UPSTREAM_MAX_RETRIES", 2),
+			BaseDelay:      getEnvDuration("UPSTREAM_RETRY_BASE_DELAY", 500*time.Millisecond),
+			MaxDelay:       getEnvDuration("UPSTREAM_RETRY_MAX_DELAY", 8*time.Second),
+			ConnectTimeout: getEnvDuration("UPSTREAM_CONNECT_TIMEOUT", 10*time.Second),
+			HeaderTimeout:  getEnvDuration("UPSTREAM_HEADER_TIMEOUT", 120*time.Second),
+		}
+		// No overall client timeout: responses may legitimately run for minutes
+		upstreamClient = &http.Client{
+			Transport: units.NewTransport(upstreamCfg.ConnectTimeout, 0),
+		}
+		upstreamStreamClient = &http.Client{
+			Transport: units.NewTransport(upstreamCfg.ConnectTimeout, upstreamCfg.HeaderTimeout),
+		}
+	})
+	if stream {
+		return upstreamStreamClient, upstreamCfg
+	}
+	return upstreamClient, upstreamCfg
+}
+
+func forwardToOpenAI(ctx context.Context, req *ChatCompletionRequest, apiKey string) (*http.Response, error) {
+	apiBase := os.Getenv("APIBASE")
+	if apiBase == "" {
+		apiBase = "https://api.openai.com"
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing request: %v", err)
+	}
+
+	client, cfg := getUpstream(req.Stream)
+	for attempt := 0; ; attempt++ {
+		openaiReq, err := http.NewRequestWithContext(ctx, "POST", apiBase+"/v1/chat/completions", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating OpenAI request: %v", err)
+		}
+		openaiReq.Header.Set("Content-Type", "application/json")
+		openaiReq.Header.Set("Authorization", "Bearer "+apiKey)
+
+		resp, err := client.Do(openaiReq)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Once the request may have reached the upstream, a retry could run
+		// the completion twice, so only connection failures are retried
+		if err != nil && !isRetryableError(err) {
+			return nil, fmt.Errorf("error contacting upstream: %v", err)
+		}
+
+		delay := backoffDelay(cfg, attempt)
+		if err == nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				// Waiting longer than we are willing to hold the client is pointless
+				if retryAfter > cfg.MaxDelay {
+					return resp, nil
+				}
+				delay = retryAfter
+			}
+		}
+
+		if attempt >= cfg.MaxRetries {
+			if err != nil {
+				return nil, fmt.Errorf("error contacting upstream after %d attempts: %v", attempt+1, err)
+			}
+			// Hand the final error response to the caller so its status and body can be reported
+			return resp, nil
+		}
+
+		if err != nil {
+			log.Printf("Upstream request failed (attempt %d/%d): %v, retrying in %v", attempt+1, cfg.MaxRetries+1, err, delay)
+		} else {
+			log.Printf("Upstream returned %d (attempt %d/%d), retrying in %v", resp.StatusCode, attempt+1, cfg.MaxRetries+1, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// completeChat sends a non-streaming request and returns the first choice's
+// text together with the total tokens used
+func completeChat(ctx context.Context, req *ChatCompletionRequest, apiKey string) (string, int, error) {
+	resp, err := forwardToOpenAI(ctx, req, apiKey)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := readUpstreamError(resp)
+		return "", 0, fmt.Errorf("%s", apiErr.Message)
+	}
+
+	var completion ChatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", 0, fmt.Errorf("error parsing OpenAI response: %v", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", 0, fmt.Errorf("empty OpenAI response")
+	}
+
+	content, _ := completion.Choices[0].Message["content"].(string)
+	tokens := 0
+	if completion.Usage != nil {
+		tokens = completion.Usage.TotalTokens
+	}
+	return content, tokens, nil
+}
+
+// isRetryableStatus reports whether an upstream status code indicates a transient failure
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// isRetryableError reports whether a transport error happened before the
+// request was sent, such as a refused connection or a failed DNS lookup
+func isRetryableError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// backoffDelay returns an exponential backoff delay with jitter for the given attempt
+func backoffDelay(cfg upstreamConfig, attempt int) time.Duration {
+	delay := cfg.BaseDelay
+	for i := 0; i < attempt && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// Keep at least half of the delay and randomise the rest
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
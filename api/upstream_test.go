@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/liyown/search4ai-go/units"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	// A port that was just released refuses connections
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + ln.Addr().String()
+	ln.Close()
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	client := &http.Client{Transport: units.NewTransport(time.Second, 50*time.Millisecond)}
+
+	tests := []struct {
+		name string
+		url  string
+		want bool
+	}{
+		{"connection refused", closedURL, true},
+		{"response header timeout", slow.URL, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Post(tt.url, "application/json", nil)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatal("request succeeded, want a transport error")
+			}
+			if got := isRetryableError(err); got != tt.want {
+				t.Errorf("isRetryableError(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+// setUpstreamConfig replaces the upstream settings for a test; the next caller
+// after the test reads them from env again
+func setUpstreamConfig(t *testing.T, cfg upstreamConfig) {
+	t.Helper()
+	upstreamOnce.Do(func() {})
+	upstreamCfg = cfg
+	upstreamClient = &http.Client{Transport: units.NewTransport(time.Second, 0)}
+	upstreamStreamClient = upstreamClient
+	t.Cleanup(func() { upstreamOnce = sync.Once{} })
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		// min and max bound the parsed delay, as HTTP dates are relative to now
+		min, max time.Duration
+		ok       bool
+	}{
+		{name: "empty", value: ""},
+		{name: "seconds", value: "3", min: 3 * time.Second, max: 3 * time.Second, ok: true},
+		{name: "zero seconds", value: "0", ok: true},
+		{name: "negative seconds", value: "-1"},
+		{name: "HTTP date", value: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), min: 28 * time.Second, max: 30 * time.Second, ok: true},
+		{name: "past HTTP date", value: "Wed, 21 Oct 2015 07:28:00 GMT", ok: true},
+		{name: "invalid", value: "soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value)
+			if ok != tt.ok || got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, want %v..%v, %v", tt.value, got, ok, tt.min, tt.max, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	cfg := upstreamConfig{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, full := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		if got := backoffDelay(cfg, attempt); got < full/2 || got > full {
+			t.Errorf("backoffDelay(attempt %d) = %v, want %v..%v", attempt, got, full/2, full)
+		}
+	}
+}
+
+func TestForwardToOpenAIRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		// statuses are returned in turn, repeating the last one
+		statuses   []int
+		retryAfter string
+		requests   int32
+		status     int
+	}{
+		{name: "503 until retries run out", statuses: []int{503}, requests: 3, status: 503},
+		{name: "recovers after 503s", statuses: []int{503, 502, 200}, requests: 3, status: 200},
+		{name: "429 honours a short Retry-After", statuses: []int{429, 200}, retryAfter: "0", requests: 2, status: 200},
+		{name: "Retry-After beyond MaxDelay returns immediately", statuses: []int{503}, retryAfter: "120", requests: 1, status: 503},
+		{name: "client errors are not retried", statuses: []int{400}, requests: 1, status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(atomic.AddInt32(&requests, 1))
+				status := tt.statuses[len(tt.statuses)-1]
+				if n <= len(tt.statuses) {
+					status = tt.statuses[n-1]
+				}
+				if status != http.StatusOK && tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(`{"attempt":` + strconv.Itoa(n) + `}`))
+			}))
+			defer srv.Close()
+
+			t.Setenv("APIBASE", srv.URL)
+			setUpstreamConfig(t, upstreamConfig{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Second})
+
+			start := time.Now()
+			resp, err := forwardToOpenAI(context.Background(), &ChatCompletionRequest{Model: "gpt-4o"}, "test-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.requests {
+				t.Errorf("upstream received %d requests, want %d", got, tt.requests)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("took %v, want no long waits", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package stream
+
+import "strconv"
+
+// maxMarkerDigits bounds the length of a citation marker such as [12]
+const maxMarkerDigits = 3
+
+// CitationTracker validates [n] citation markers in streamed text.
+// Markers referring to unknown sources are stripped, and markers split
+// across deltas are held back until they can be checked. Text inside
+// inline code spans and fenced code blocks, such as arr[5], is passed
+// through untouched.
+type CitationTracker struct {
+	valid   func(n int) bool
+	pending string
+	used    []int
+	seen    map[int]bool
+	// ticks counts the backticks of the run being read, which may be split across deltas
+	ticks int
+	// code is the length of the backtick run that opened the current code span, or 0 in prose
+	code int
+}
+
+// NewCitationTracker creates a tracker that keeps markers accepted by valid
+func NewCitationTracker(valid func(n int) bool) *CitationTracker {
+	return &CitationTracker{
+		valid: valid,
+		seen:  make(map[int]bool),
+	}
+}
+
+// Filter returns the part of text that is safe to emit
+func (t *CitationTracker) Filter(text string) string {
+	var out []byte
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if t.pending == "" {
+			if ch == '`' {
+				t.ticks++
+			} else {
+				t.endTicks(ch)
+				if ch == '[' && t.code == 0 {
+					t.pending = "["
+					continue
+				}
+			}
+			out = append(out, ch)
+			continue
+		}
+
+		switch {
+		case ch >= '0' && ch <= '9' && len(t.pending) <= maxMarkerDigits:
+			t.pending += string(ch)
+		case ch == ']' && len(t.pending) > 1:
+			out = append(out, t.resolve()...)
+		default:
+			// Not a marker after all: release the buffered text and look at ch again
+			out = append(out, t.pending...)
+			t.pending = ""
+			i--
+		}
+	}
+	return string(out)
+}
+
+// endTicks updates the code span state once a backtick run ends before ch.
+// A run opens a span in prose and closes one opened by a run of the same
+// length, so ``` fences are only closed by another fence. An unclosed inline
+// span ends at the line break, as a stray backtick in prose is left literal.
+func (t *CitationTracker) endTicks(ch byte) {
+	if t.ticks > 0 {
+		if t.code == 0 {
+			t.code = t.ticks
+		} else if t.ticks == t.code {
+			t.code = 0
+		}
+		t.ticks = 0
+	}
+	if ch == '\n' && t.code > 0 && t.code < 3 {
+		t.code = 0
+	}
+}
+
+// Flush returns any text still held back at the end of the stream
+func (t *CitationTracker) Flush() string {
+	pending := t.pending
+	t.pending = ""
+	return pending
+}
+
+// Used returns the valid markers in order of first appearance
+func (t *CitationTracker) Used() []int {
+	return t.used
+}
+
+// resolve completes the pending marker, returning it if valid and dropping it otherwise
+func (t *CitationTracker) resolve() string {
+	marker := t.pending + "]"
+	t.pending = ""
+
+	n, err := strconv.Atoi(marker[1 : len(marker)-1])
+	// [0] is more likely an index expression than a citation
+	if err != nil || n == 0 {
+		return marker
+	}
+	if !t.valid(n) {
+		return ""
+	}
+	if !t.seen[n] {
+		t.seen[n] = true
+		t.used = append(t.used, n)
+	}
+	return marker
+}
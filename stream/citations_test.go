@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCitationTrackerFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   string
+		used   []int
+	}{
+		{
+			name:   "valid markers are kept",
+			deltas: []string{"Go 1.23 added iterators [1][2]."},
+			want:   "Go 1.23 added iterators [1][2].",
+			used:   []int{1, 2},
+		},
+		{
+			name:   "marker split across deltas",
+			deltas: []string{"Released in August [", "2", "]", " and [1", "]."},
+			want:   "Released in August [2] and [1].",
+			used:   []int{2, 1},
+		},
+		{
+			name:   "out of range markers are stripped",
+			deltas: []string{"See [4] and [2", "7] but keep [3]."},
+			want:   "See  and  but keep [3].",
+			used:   []int{3},
+		},
+		{
+			name:   "non-markers are released",
+			deltas: []string{"[0] and [a] and [12345] and [", "x]"},
+			want:   "[0] and [a] and [12345] and [x]",
+		},
+		{
+			name:   "inline code is left alone",
+			deltas: []string{"Index with `arr[5]` as in [1], not ``m[", "7]`` or [9]."},
+			want:   "Index with `arr[5]` as in [1], not ``m[7]`` or .",
+			used:   []int{1},
+		},
+		{
+			name:   "fenced code block is left alone",
+			deltas: []string{"Example [2]:\n``", "`go\nx := arr[5]\ny := `raw[6]`\n``", "`\nAfter the block [7]."},
+			want:   "Example [2]:\n```go\nx := arr[5]\ny := `raw[6]`\n```\nAfter the block .",
+			used:   []int{2},
+		},
+		{
+			name:   "unclosed inline code ends at the line break",
+			deltas: []string{"A stray ` backtick [5]\nthen [5] and [1]"},
+			want:   "A stray ` backtick [5]\nthen  and [1]",
+			used:   []int{1},
+		},
+		{
+			name:   "pending marker is flushed at the end",
+			deltas: []string{"Trailing [1", "2"},
+			want:   "Trailing [12",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewCitationTracker(func(n int) bool { return n >= 1 && n <= 3 })
+			var sb strings.Builder
+			for _, delta := range tt.deltas {
+				sb.WriteString(tracker.Filter(delta))
+			}
+			sb.WriteString(tracker.Flush())
+
+			if got := sb.String(); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+			if got := tracker.Used(); !reflect.DeepEqual(got, tt.used) {
+				t.Errorf("used = %v, want %v", got, tt.used)
+			}
+		})
+	}
+}
@@ -2,52 +2,81 @@ package stream
 
 // ToolCallCollector collects and manages tool calls
 type ToolCallCollector struct {
-	currentToolCall map[string]interface{}
-	toolCalls       []map[string]interface{}
+	toolCalls       []collectedToolCall
 	toolCallResults []map[string]interface{}
 }
 
+// collectedToolCall is a tool call being assembled from streamed fragments
+type collectedToolCall struct {
+	index int
+	call  map[string]interface{}
+}
+
 // NewToolCallCollector creates a new tool call collector
 func NewToolCallCollector() *ToolCallCollector {
 	return &ToolCallCollector{
-		currentToolCall: make(map[string]interface{}),
-		toolCalls:       make([]map[string]interface{}, 0),
+		toolCalls: make([]collectedToolCall, 0),
 	}
 }
 
-// CollectToolCall collects a tool call
+// CollectToolCall collects a tool call fragment.
+// Fragments are matched by index; a fragment carrying a new ID starts a new call
+// even when the provider does not number parallel calls.
 func (tc *ToolCallCollector) CollectToolCall(call ToolCall) {
-	if tc.currentToolCall["id"] == nil {
-		tc.currentToolCall = map[string]interface{}{
-			"id":   call.ID,
-			"type": call.Type,
-			"function": map[string]interface{}{
-				"name":      call.Function.Name,
-				"arguments": call.Function.Arguments,
-			},
-		}
-	} else {
-		if call.ID != "" {
-			tc.currentToolCall["id"] = call.ID
+	current := tc.find(call.Index)
+	if current == nil || (call.ID != "" && current["id"] != "" && current["id"] != call.ID) {
+		callType := call.Type
+		if callType == "" {
+			callType = "function"
 		}
-		if call.Function.Name != "" {
-			tc.currentToolCall["function"].(map[string]interface{})["name"] = call.Function.Name
-		}
-		if call.Function.Arguments != "" {
-			args := tc.currentToolCall["function"].(map[string]interface{})["arguments"].(string)
-			args += call.Function.Arguments
-			tc.currentToolCall["function"].(map[string]interface{})["arguments"] = args
+		tc.toolCalls = append(tc.toolCalls, collectedToolCall{
+			index: call.Index,
+			call: map[string]interface{}{
+				"id":   call.ID,
+				"type": callType,
+				"function": map[string]interface{}{
+					"name":      call.Function.Name,
+					"arguments": call.Function.Arguments,
+				},
+			},
+		})
+		return
+	}
+
+	if call.ID != "" {
+		current["id"] = call.ID
+	}
+	function := current["function"].(map[string]interface{})
+	if call.Function.Name != "" {
+		function["name"] = call.Function.Name
+	}
+	if call.Function.Arguments != "" {
+		function["arguments"] = function["arguments"].(string) + call.Function.Arguments
+	}
+}
+
+// find returns the most recent call with the given index
+func (tc *ToolCallCollector) find(index int) map[string]interface{} {
+	for i := len(tc.toolCalls) - 1; i >= 0; i-- {
+		if tc.toolCalls[i].index == index {
+			return tc.toolCalls[i].call
 		}
 	}
+	return nil
+}
+
+// HasToolCalls reports whether any tool call has been collected
+func (tc *ToolCallCollector) HasToolCalls() bool {
+	return len(tc.toolCalls) > 0
 }
 
 // GetToolCalls returns the collected tool calls
 func (tc *ToolCallCollector) GetToolCalls() []map[string]interface{} {
-	if tc.currentToolCall["id"] != nil {
-		tc.toolCalls = append(tc.toolCalls, tc.currentToolCall)
-		tc.currentToolCall = make(map[string]interface{})
+	toolCalls := make([]map[string]interface{}, 0, len(tc.toolCalls))
+	for _, collected := range tc.toolCalls {
+		toolCalls = append(toolCalls, collected.call)
 	}
-	return tc.toolCalls
+	return toolCalls
 }
 
 // GetToolCallResults returns the collected tool call results
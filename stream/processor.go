@@ -1,12 +1,10 @@
 package stream
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"strings"
 )
 
@@ -15,6 +13,9 @@ type Processor struct {
 	writer            io.Writer
 	message           *Message
 	toolCallCollector *ToolCallCollector
+	citations         *CitationTracker
+	lastResponse      StreamResponse
+	err               error
 }
 
 // NewProcessor creates a new stream processor
@@ -30,134 +31,149 @@ func NewProcessor(w io.Writer) *Processor {
 	}
 }
 
+// SetCitationTracker enables citation marker validation on streamed content
+func (p *Processor) SetCitationTracker(t *CitationTracker) {
+	p.citations = t
+}
+
 // ProcessStream processes the stream and returns the message, collected tool calls, and whether tool execution is needed
-func (p *Processor) ProcessStream(body io.ReadCloser, searchResults []map[string]interface{}) (*Message, []map[string]interface{}, bool) {
-	scanner := bufio.NewScanner(body)
-	chunkCount := 0
-	isToolCallMessage := false
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+func (p *Processor) ProcessStream(body io.ReadCloser) (*Message, []map[string]interface{}, bool) {
+	reader := NewSSEReader(body)
+
+	for {
+		event, err := reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("Error reading stream: %v", err)
+				p.err = fmt.Errorf("error reading upstream stream: %v", err)
+			}
+			break
 		}
 
-		data := strings.TrimPrefix(line, "data: ")
+		data := strings.TrimSpace(event.Data)
 		if data == "[DONE]" {
 			break
 		}
 
+		if event.Event == "error" {
+			p.err = fmt.Errorf("upstream stream error: %s", data)
+			break
+		}
+
 		var response StreamResponse
 		if err := json.Unmarshal([]byte(data), &response); err != nil {
 			log.Printf("Error parsing stream data: %v", err)
 			continue
 		}
 
-		if len(response.Choices) == 0 {
-			continue
+		if len(response.Error) > 0 && string(response.Error) != "null" {
+			p.err = fmt.Errorf("upstream stream error: %s", string(response.Error))
+			break
 		}
 
-		// Check for tool calls in the second chunk
-		chunkCount++
-		if chunkCount == 2 {
-			isToolCallMessage = len(response.Choices[0].Delta.ToolCalls) > 0
+		if len(response.Choices) == 0 {
+			continue
 		}
 
+		p.lastResponse = response
 		choice := response.Choices[0]
 		delta := choice.Delta
 
-		// Only process after we know if it's a tool call message (after second chunk)
-		if chunkCount >= 2 {
-			if isToolCallMessage {
-				p.handleFunctionCall(delta)
-			} else {
-				p.handleContent(delta, response, searchResults)
-			}
+		// Every delta is inspected: providers differ in which chunk carries the role,
+		// and a single turn may contain both text and tool calls
+		if delta.Role != "" {
+			p.message.Role = delta.Role
+		}
+		if len(delta.ToolCalls) > 0 {
+			p.handleFunctionCall(delta)
+		}
+		if delta.Content != "" {
+			p.handleContent(delta, response)
 		}
 
 		// Check if we're done with this stream
 		if choice.FinishReason != "" {
-			if choice.FinishReason == "tool_calls" {
-				// Return collected tool calls for execution
-				return p.message, p.toolCallCollector.GetToolCalls(), true
+			// Some providers finish tool call turns with "stop", so rely on what was collected
+			p.flushCitations()
+			if p.toolCallCollector.HasToolCalls() {
+				return p.finishToolCalls()
 			}
-			// If finish reason is not tool_calls, we're done
+			p.writeFinish(response)
 			return p.message, nil, false
 		}
 	}
 
+	// The stream ended without a finish reason
+	p.flushCitations()
+	if p.err == nil && p.toolCallCollector.HasToolCalls() {
+		return p.finishToolCalls()
+	}
 	return p.message, nil, false
 }
 
-func (p *Processor) handleContent(delta Delta, response StreamResponse, searchResults []map[string]interface{}) {
-	if delta.Role != "" {
-		p.message.Role = delta.Role
+// finishToolCalls returns the collected tool calls for execution
+func (p *Processor) finishToolCalls() (*Message, []map[string]interface{}, bool) {
+	toolCalls := p.toolCallCollector.GetToolCalls()
+	p.message.ToolCalls = toolCalls
+	return p.message, toolCalls, true
+}
+
+// Err returns the error that ended the last ProcessStream call, if any
+func (p *Processor) Err() error {
+	return p.err
+}
+
+func (p *Processor) handleContent(delta Delta, response StreamResponse) {
+	content := delta.Content
+	if p.citations != nil {
+		content = p.citations.Filter(content)
 	}
+	p.writeContent(response, content)
+}
 
-	if delta.Content != "" {
-		p.message.Content += delta.Content
-		// Stream content to client with proper escaping
-		escapedContent := strings.Replace(delta.Content, "\"", "\\\"", -1)
-		escapedContent = strings.Replace(escapedContent, "\n", "\\n", -1)
-
-		// Include metadata from original response and add search results
-		streamResp := StreamResponse{
-			ID:                response.ID,
-			Object:            response.Object,
-			Created:           response.Created,
-			Model:             response.Model,
-			SystemFingerprint: response.SystemFingerprint,
-			Choices: []StreamChoice{
-				{
-					Delta: Delta{
-						Content: escapedContent,
-					},
-					FinishReason: response.Choices[0].FinishReason,
-				},
-			},
-			SearchResults: searchResults,
-		}
+// flushCitations emits text held back by the citation tracker
+func (p *Processor) flushCitations() {
+	if p.citations != nil {
+		p.writeContent(p.lastResponse, p.citations.Flush())
+	}
+}
 
-		respBytes, err := json.Marshal(streamResp)
-		if err != nil {
-			log.Printf("Error marshaling response: %v", err)
-			return
-		}
+func (p *Processor) writeContent(response StreamResponse, content string) {
+	if content == "" {
+		return
+	}
+	p.message.Content += content
 
-		fmt.Fprintf(p.writer, "data: %s\n\n", string(respBytes))
-		if f, ok := p.writer.(http.Flusher); ok {
-			f.Flush()
-		}
+	// Include metadata from original response
+	p.writeChunk(response, StreamChoice{
+		Delta: Delta{
+			Content: content,
+		},
+	})
+}
+
+// writeFinish forwards the final chunk carrying the finish reason
+func (p *Processor) writeFinish(response StreamResponse) {
+	p.writeChunk(response, StreamChoice{
+		FinishReason: response.Choices[0].FinishReason,
+	})
+}
+
+func (p *Processor) writeChunk(response StreamResponse, choice StreamChoice) {
+	streamResp := StreamResponse{
+		ID:                response.ID,
+		Object:            response.Object,
+		Created:           response.Created,
+		Model:             response.Model,
+		SystemFingerprint: response.SystemFingerprint,
+		Choices:           []StreamChoice{choice},
 	}
+
+	writeResponse(p.writer, streamResp)
 }
 
 func (p *Processor) handleFunctionCall(delta Delta) {
-	if len(delta.ToolCalls) > 0 {
-		p.toolCallCollector.CollectToolCall(delta.ToolCalls[0])
-
-		if len(p.message.ToolCalls) == 0 {
-			p.message.ToolCalls = append(p.message.ToolCalls, map[string]interface{}{
-				"id":   delta.ToolCalls[0].ID,
-				"type": "function",
-				"function": map[string]interface{}{
-					"name":      delta.ToolCalls[0].Function.Name,
-					"arguments": delta.ToolCalls[0].Function.Arguments,
-				},
-			})
-		} else {
-			// Append to existing tool call
-			currentTool := p.message.ToolCalls[0]
-			if delta.ToolCalls[0].ID != "" {
-				currentTool["id"] = delta.ToolCalls[0].ID
-			}
-			if delta.ToolCalls[0].Function.Name != "" {
-				currentTool["function"].(map[string]interface{})["name"] = delta.ToolCalls[0].Function.Name
-			}
-			if delta.ToolCalls[0].Function.Arguments != "" {
-				args := currentTool["function"].(map[string]interface{})["arguments"].(string)
-				args += delta.ToolCalls[0].Function.Arguments
-				currentTool["function"].(map[string]interface{})["arguments"] = args
-			}
-		}
+	for _, call := range delta.ToolCalls {
+		p.toolCallCollector.CollectToolCall(call)
 	}
 }
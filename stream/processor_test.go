@@ -0,0 +1,137 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// replay feeds a recorded upstream stream from testdata through a Processor
+func replay(t *testing.T, name string) (*Processor, *bytes.Buffer, *Message, []map[string]interface{}, bool) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	p := NewProcessor(&out)
+	msg, toolCalls, needsTools := p.ProcessStream(io.NopCloser(bytes.NewReader(data)))
+	return p, &out, msg, toolCalls, needsTools
+}
+
+func toolCall(id, name, arguments string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   id,
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":      name,
+			"arguments": arguments,
+		},
+	}
+}
+
+func TestProcessStreamRecorded(t *testing.T) {
+	tests := []struct {
+		name       string
+		fixture    string
+		content    string
+		toolCalls  []map[string]interface{}
+		needsTools bool
+		// output lists strings the forwarded chunks must contain
+		output []string
+	}{
+		{
+			name:    "OpenAI parallel tool calls",
+			fixture: "openai_parallel_tool_calls.txt",
+			toolCalls: []map[string]interface{}{
+				toolCall("call_abc", "search", `{"query": "go 1.23"}`),
+				toolCall("call_def", "crawler", `{"url": "https://go.dev/"}`),
+			},
+			needsTools: true,
+		},
+		{
+			name:    "DeepSeek reasoning with keep-alive comments and usage",
+			fixture: "deepseek_reasoning.txt",
+			content: "Go 1.23 added range-over-func.",
+			output:  []string{`"model":"deepseek-reasoner"`, `"finish_reason":"stop"`},
+		},
+		{
+			name:    "Anthropic-compatible tool calls finished with stop",
+			fixture: "anthropic_compat_tool_calls.txt",
+			content: "Let me look that up.",
+			toolCalls: []map[string]interface{}{
+				toolCall("toolu_01A", "search", `{"query":"golang news"}`),
+				toolCall("toolu_01B", "wikipedia", `{"query":"Go (programming language)"}`),
+			},
+			needsTools: true,
+			output:     []string{"Let me look that up."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, out, msg, toolCalls, needsTools := replay(t, tt.fixture)
+			if err := p.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if msg.Role != "assistant" {
+				t.Errorf("Role = %q", msg.Role)
+			}
+			if msg.Content != tt.content {
+				t.Errorf("Content = %q, want %q", msg.Content, tt.content)
+			}
+			if needsTools != tt.needsTools {
+				t.Errorf("needsTools = %v, want %v", needsTools, tt.needsTools)
+			}
+			if !reflect.DeepEqual(toolCalls, tt.toolCalls) {
+				t.Errorf("toolCalls = %v, want %v", toolCalls, tt.toolCalls)
+			}
+			if tt.needsTools && !reflect.DeepEqual(msg.ToolCalls, tt.toolCalls) {
+				t.Errorf("message ToolCalls = %v", msg.ToolCalls)
+			}
+			for _, want := range tt.output {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output does not contain %q:\n%s", want, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "reasoning_content") || strings.Contains(out.String(), "tool_calls") {
+				t.Errorf("output forwards upstream-only fields:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestProcessStreamErrors(t *testing.T) {
+	t.Run("error field in a chunk", func(t *testing.T) {
+		p, _, msg, toolCalls, needsTools := replay(t, "upstream_error.txt")
+		if err := p.Err(); err == nil || !strings.Contains(err.Error(), "server_error") {
+			t.Errorf("Err() = %v, want the upstream error", err)
+		}
+		if msg.Content != "Partial" || toolCalls != nil || needsTools {
+			t.Errorf("Content = %q, toolCalls = %v, needsTools = %v", msg.Content, toolCalls, needsTools)
+		}
+	})
+
+	t.Run("error event", func(t *testing.T) {
+		stream := "event: error\r\ndata: {\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}\r\n\r\n"
+		p := NewProcessor(io.Discard)
+		p.ProcessStream(io.NopCloser(strings.NewReader(stream)))
+		if err := p.Err(); err == nil || !strings.Contains(err.Error(), "Overloaded") {
+			t.Errorf("Err() = %v, want the error event", err)
+		}
+	})
+
+	t.Run("tool calls of a stream cut short are not executed", func(t *testing.T) {
+		stream := "data: {\"choices\":[{\"index\":0,\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"type\":\"function\",\"function\":{\"name\":\"search\",\"arguments\":\"{\\\"qu\"}}]}}]}\n\n" +
+			"data: {\"error\":{\"message\":\"connection reset\"}}\n\n"
+		p := NewProcessor(io.Discard)
+		_, toolCalls, needsTools := p.ProcessStream(io.NopCloser(strings.NewReader(stream)))
+		if p.Err() == nil || toolCalls != nil || needsTools {
+			t.Errorf("Err() = %v, toolCalls = %v, needsTools = %v", p.Err(), toolCalls, needsTools)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/liyown/search4ai-go/units"
+)
+
+// Progress event types emitted while tools are running
+const (
+	ProgressSearchStarted   = "search.started"
+	ProgressSearchCompleted = "search.completed"
+	ProgressSearchFailed    = "search.failed"
+	ProgressCrawlStarted    = "crawl.started"
+	ProgressCrawlCompleted  = "crawl.completed"
+	ProgressCrawlFailed     = "crawl.failed"
+
+	ProgressResearchPlan     = "research.plan"
+	ProgressResearchStep     = "research.step"
+	ProgressResearchEvaluate = "research.evaluate"
+	ProgressResearchReport   = "research.report"
+)
+
+// ProgressEvent describes the state of a tool call while it executes
+type ProgressEvent struct {
+	Type        string   `json:"type"`
+	ToolCallID  string   `json:"tool_call_id,omitempty"`
+	Query       string   `json:"query,omitempty"`
+	Queries     []string `json:"queries,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	ResultCount int      `json:"result_count,omitempty"`
+	URLs        []string `json:"urls,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Step        int      `json:"step,omitempty"`
+	Questions   []string `json:"questions,omitempty"`
+	Message     string   `json:"message,omitempty"`
+}
+
+// WriteProgress emits a progress event as a chat completion chunk with an empty delta.
+// Ordinary clients see a no-op chunk and ignore the extra tool_progress field.
+func WriteProgress(w io.Writer, model string, event ProgressEvent) {
+	streamResp := newExtraChunk(model)
+	streamResp.ToolProgress = &event
+	writeResponse(w, streamResp)
+}
+
+// WriteSearchResults emits search results in a dedicated chunk with an empty delta
+func WriteSearchResults(w io.Writer, model string, results []units.SearchResult) {
+	streamResp := newExtraChunk(model)
+	streamResp.SearchResults = results
+	writeResponse(w, streamResp)
+}
+
+// WriteCrawlResults emits crawled pages in a dedicated chunk with an empty delta
+func WriteCrawlResults(w io.Writer, model string, results []units.CrawlResult) {
+	streamResp := newExtraChunk(model)
+	streamResp.CrawlResults = results
+	writeResponse(w, streamResp)
+}
+
+// WriteCitations emits the citations used in the answer in a dedicated chunk
+func WriteCitations(w io.Writer, model string, citations []Citation) {
+	streamResp := newExtraChunk(model)
+	streamResp.Citations = citations
+	writeResponse(w, streamResp)
+}
+
+// newExtraChunk creates a chunk with an empty delta for carrying proxy data
+func newExtraChunk(model string) StreamResponse {
+	return StreamResponse{
+		Object:  "chat.completion.chunk",
+		Created: time.Now().Unix(),
+		Model:   model,
+		Choices: []StreamChoice{
+			{
+				Delta: Delta{},
+			},
+		},
+	}
+}
+
+// writeResponse writes a chunk as an SSE data line and flushes it
+func writeResponse(w io.Writer, streamResp StreamResponse) {
+	respBytes, err := json.Marshal(streamResp)
+	if err != nil {
+		log.Printf("Error marshaling response: %v", err)
+		return
+	}
+
+	fmt.Fprintf(w, "data: %s\n\n", string(respBytes))
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Event represents a single server-sent event
+type Event struct {
+	Event string
+	Data  string
+	ID    string
+}
+
+// SSEReader reads server-sent events from a stream.
+// It follows the EventSource parsing rules: lines may end in LF, CRLF or CR,
+// comment lines starting with ':' are skipped, multiple data fields are joined
+// with newlines and there is no limit on line length.
+type SSEReader struct {
+	r *bufio.Reader
+}
+
+// NewSSEReader creates a new SSE reader
+func NewSSEReader(r io.Reader) *SSEReader {
+	return &SSEReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next event in the stream, or io.EOF when the stream ends
+func (s *SSEReader) Next() (*Event, error) {
+	event := &Event{}
+	var data strings.Builder
+	hasData := false
+
+	for {
+		line, err := s.readLine()
+		if err != nil {
+			// Be lenient with streams that end without a trailing blank line
+			if err == io.EOF && hasData {
+				event.Data = strings.TrimSuffix(data.String(), "\n")
+				return event, nil
+			}
+			return nil, err
+		}
+
+		// A blank line dispatches the event
+		if line == "" {
+			if !hasData {
+				event = &Event{}
+				continue
+			}
+			event.Data = strings.TrimSuffix(data.String(), "\n")
+			return event, nil
+		}
+
+		// Comments are used as keep-alives
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field = line[:i]
+			value = strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "event":
+			event.Event = value
+		case "id":
+			event.ID = value
+		}
+	}
+}
+
+// readLine reads a single line without its terminator
+func (s *SSEReader) readLine() (string, error) {
+	var line []byte
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+
+		switch b {
+		case '\n':
+			return string(line), nil
+		case '\r':
+			if next, err := s.r.Peek(1); err == nil && next[0] == '\n' {
+				s.r.ReadByte()
+			}
+			return string(line), nil
+		default:
+			line = append(line, b)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package stream
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readEvents reads all events from a stream until EOF
+func readEvents(t *testing.T, stream string) []Event {
+	t.Helper()
+	reader := NewSSEReader(strings.NewReader(stream))
+	var events []Event
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		events = append(events, *event)
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	large := strings.Repeat("x", 100*1024)
+
+	tests := []struct {
+		name   string
+		stream string
+		want   []Event
+	}{
+		{
+			name:   "LF line endings",
+			stream: "data: one\n\ndata: two\n\n",
+			want:   []Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:   "CRLF line endings",
+			stream: "data: one\r\n\r\ndata: two\r\n\r\n",
+			want:   []Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:   "CR line endings",
+			stream: "data: one\r\rdata: two\r\r",
+			want:   []Event{{Data: "one"}, {Data: "two"}},
+		},
+		{
+			name:   "data without a space",
+			stream: "data:{\"a\":1}\n\n",
+			want:   []Event{{Data: `{"a":1}`}},
+		},
+		{
+			name:   "only the first space is removed",
+			stream: "data:  indented\n\n",
+			want:   []Event{{Data: " indented"}},
+		},
+		{
+			name:   "multi-line data",
+			stream: "data: first\ndata: second\ndata:\ndata: fourth\n\n",
+			want:   []Event{{Data: "first\nsecond\n\nfourth"}},
+		},
+		{
+			name:   "comment lines",
+			stream: ": keep-alive\n\n: OPENROUTER PROCESSING\ndata: payload\n: trailing comment\n\n",
+			want:   []Event{{Data: "payload"}},
+		},
+		{
+			name:   "event and id lines",
+			stream: "event: message_start\nid: 7\ndata: {}\n\nevent: error\ndata: {\"message\":\"overloaded\"}\n\n",
+			want: []Event{
+				{Event: "message_start", ID: "7", Data: "{}"},
+				{Event: "error", Data: `{"message":"overloaded"}`},
+			},
+		},
+		{
+			name:   "event without data is not dispatched",
+			stream: "event: ping\n\ndata: after\n\n",
+			want:   []Event{{Data: "after"}},
+		},
+		{
+			name:   "unknown fields are ignored",
+			stream: "retry: 3000\nfoo: bar\ndata: ok\n\n",
+			want:   []Event{{Data: "ok"}},
+		},
+		{
+			name:   "payload over 64KB",
+			stream: "data: " + large + "\n\ndata: [DONE]\n\n",
+			want:   []Event{{Data: large}, {Data: "[DONE]"}},
+		},
+		{
+			name:   "no trailing blank line",
+			stream: "data: one\n\ndata: last",
+			want:   []Event{{Data: "one"}, {Data: "last"}},
+		},
+		{
+			name:   "empty stream",
+			stream: "",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := readEvents(t, tt.stream)
+			if !reflect.DeepEqual(got, tt.want) {
+				if len(got) == len(tt.want) && len(got) > 0 && len(got[0].Data) > 100 {
+					t.Errorf("events differ (%d bytes of data)", len(got[0].Data))
+				} else {
+					t.Errorf("events = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,14 +1,24 @@
 package stream
 
+import (
+	"encoding/json"
+
+	"github.com/liyown/search4ai-go/units"
+)
+
 // StreamResponse represents a streaming response chunk
 type StreamResponse struct {
-	ID                string                   `json:"id"`
-	Object            string                   `json:"object"`
-	Created           int64                    `json:"created"`
-	Model             string                   `json:"model"`
-	Choices           []StreamChoice           `json:"choices"`
-	SystemFingerprint string                   `json:"system_fingerprint"`
-	SearchResults     []map[string]interface{} `json:"search_results,omitempty"`
+	ID                string               `json:"id"`
+	Object            string               `json:"object"`
+	Created           int64                `json:"created"`
+	Model             string               `json:"model"`
+	Choices           []StreamChoice       `json:"choices"`
+	SystemFingerprint string               `json:"system_fingerprint"`
+	SearchResults     []units.SearchResult `json:"search_results,omitempty"`
+	CrawlResults      []units.CrawlResult  `json:"crawl_results,omitempty"`
+	Citations         []Citation           `json:"citations,omitempty"`
+	ToolProgress      *ProgressEvent       `json:"tool_progress,omitempty"`
+	Error             json.RawMessage      `json:"error,omitempty"`
 }
 
 // StreamChoice represents a choice in the streaming response
@@ -47,3 +57,14 @@ type Message struct {
 	Name       string                   `json:"name,omitempty"`
 	ToolCallID string                   `json:"tool_call_id,omitempty"`
 }
+
+// Citation maps a [n] marker in the answer to its source
+type Citation struct {
+	Index       int    `json:"index"`
+	Title       string `json:"title"`
+	URL         string `json:"url"`
+	Snippet     string `json:"snippet"`
+	Source      string `json:"source,omitempty"`
+	PublishedAt string `json:"published_at,omitempty"`
+	Favicon     string `json:"favicon,omitempty"`
+}
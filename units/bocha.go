@@ -0,0 +1,111 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func searchWithBocha(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("BOCHA_KEY")
+
+	reqBody := map[string]interface{}{
+		"query":     query,
+		"count":     min(CandidateCount(), 50),
+		"summary":   true,
+		"freshness": bochaFreshness(opts.TimeRange),
+	}
+	if len(opts.IncludeDomains) > 0 {
+		reqBody["include"] = strings.Join(opts.IncludeDomains, "|")
+	}
+	if len(opts.ExcludeDomains) > 0 {
+		reqBody["exclude"] = strings.Join(opts.ExcludeDomains, "|")
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", envBaseURL("BOCHA_BASE_URL", "https://api.bochaai.com/v1")+"/web-search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var bochaResp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			WebPages struct {
+				Value []struct {
+					Name          string `json:"name"`
+					URL           string `json:"url"`
+					Snippet       string `json:"snippet"`
+					Summary       string `json:"summary"`
+					SiteName      string `json:"siteName"`
+					SiteIcon      string `json:"siteIcon"`
+					DatePublished string `json:"datePublished"`
+					Language      string `json:"language"`
+				} `json:"value"`
+			} `json:"webPages"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&bochaResp); err != nil {
+		return nil, err
+	}
+	if bochaResp.Code != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d %s", bochaResp.Code, bochaResp.Msg)
+	}
+
+	var results []SearchResult
+	for _, item := range bochaResp.Data.WebPages.Value {
+		// The summary is a longer extract of the page than the snippet
+		snippet := item.Summary
+		if snippet == "" {
+			snippet = item.Snippet
+		}
+		results = append(results, SearchResult{
+			Title:       item.Name,
+			Link:        item.URL,
+			Snippet:     snippet,
+			PublishedAt: chinaDate(item.DatePublished),
+			Source:      item.SiteName,
+			Favicon:     item.SiteIcon,
+			Language:    item.Language,
+		})
+	}
+
+	return results, nil
+}
+
+// bochaFreshness maps a time range to Bocha's freshness parameter
+func bochaFreshness(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay:
+		return "oneDay"
+	case PeriodWeek:
+		return "oneWeek"
+	case PeriodMonth:
+		return "oneMonth"
+	case PeriodYear:
+		return "oneYear"
+	case PeriodCustom:
+		start, end := tr.customDates("2006-01-02", time.Now())
+		return start + ".." + end
+	}
+	return "noLimit"
+}
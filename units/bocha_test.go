@@ -0,0 +1,54 @@
+package units
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchWithBocha(t *testing.T) {
+	var body map[string]interface{}
+	baseURL := serveJSONFixture(t, "/web-search", "bocha.json", func(r *http.Request, b map[string]interface{}) {
+		if got := r.Header.Get("Authorization"); got != "Bearer bocha-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		body = b
+	})
+	t.Setenv("SEARCH_SERVICE", "bocha")
+	t.Setenv("BOCHA_BASE_URL", baseURL+"/")
+	t.Setenv("BOCHA_KEY", "bocha-test")
+	t.Setenv("MAX_RESULTS", "5")
+
+	results, err := SearchResults("Go 1.23 迭代器", SearchOptions{
+		TimeRange:      TimeRange{Period: PeriodWeek},
+		IncludeDomains: []string{"go.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBody := map[string]interface{}{
+		"query":     "Go 1.23 迭代器",
+		"count":     float64(5),
+		"summary":   true,
+		"freshness": "oneWeek",
+		"include":   "go.dev",
+	}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("request body = %v, want %v", body, wantBody)
+	}
+
+	if links := resultLinks(results); !reflect.DeepEqual(links, []string{"https://go.dev/doc/go1.23", "https://go.dev/blog/range-functions"}) {
+		t.Fatalf("links = %q", links)
+	}
+	first := results[0]
+	if !strings.HasPrefix(first.Snippet, "Go 1.23 于 2024 年 8 月发布") || first.Source != "Go" || first.Language != "zh" ||
+		first.PublishedAt != "2024-08-13T00:00:00Z" {
+		t.Errorf("first result = %+v, want the summary as snippet", first)
+	}
+	second := results[1]
+	if second.Snippet != "This is the blog post version of my talk at GopherCon 2024." || second.PublishedAt != "2024-08-19T16:00:00Z" {
+		t.Errorf("second result = %+v, want the snippet when the summary is empty", second)
+	}
+}
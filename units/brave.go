@@ -0,0 +1,103 @@
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+func searchWithBrave(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("BRAVE_KEY")
+
+	apiURL := fmt.Sprintf("%s/res/v1/web/search?q=%s&count=%d&extra_snippets=true",
+		envBaseURL("BRAVE_BASE_URL", "https://api.search.brave.com"),
+		url.QueryEscape(query),
+		min(CandidateCount(), 20))
+	if freshness := braveFreshness(opts.TimeRange); freshness != "" {
+		apiURL += "&freshness=" + freshness
+	}
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Subscription-Token", apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var braveResp struct {
+		Web struct {
+			Results []struct {
+				Title         string   `json:"title"`
+				URL           string   `json:"url"`
+				Description   string   `json:"description"`
+				Age           string   `json:"age"`
+				PageAge       string   `json:"page_age"`
+				Language      string   `json:"language"`
+				ExtraSnippets []string `json:"extra_snippets"`
+				Profile       struct {
+					Name string `json:"name"`
+				} `json:"profile"`
+				MetaURL struct {
+					Hostname string `json:"hostname"`
+					Favicon  string `json:"favicon"`
+				} `json:"meta_url"`
+				Thumbnail struct {
+					Src string `json:"src"`
+				} `json:"thumbnail"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&braveResp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, item := range braveResp.Web.Results {
+		published := item.PageAge
+		if published == "" {
+			published = item.Age
+		}
+		results = append(results, SearchResult{
+			// Brave highlights query terms with <strong> tags
+			Title:       htmlToText(item.Title),
+			Link:        item.URL,
+			Snippet:     htmlToText(item.Description),
+			PublishedAt: published,
+			Source:      item.Profile.Name,
+			Favicon:     item.MetaURL.Favicon,
+			Thumbnail:   item.Thumbnail.Src,
+			Language:    item.Language,
+			Content:     htmlToText(strings.Join(item.ExtraSnippets, "\n")),
+		})
+	}
+
+	return results, nil
+}
+
+// braveFreshness maps a time range to Brave's freshness parameter
+func braveFreshness(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear:
+		return "p" + tr.Period[:1]
+	case PeriodCustom:
+		start, end := tr.customDates("2006-01-02", time.Now())
+		return start + "to" + end
+	}
+	return ""
+}
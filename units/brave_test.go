@@ -0,0 +1,76 @@
+package units
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchWithBrave(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeRange TimeRange
+		freshness string
+	}{
+		{"no time range", TimeRange{}, ""},
+		{"week", TimeRange{Period: PeriodWeek}, "pw"},
+		{"year", TimeRange{Period: PeriodYear}, "py"},
+		{
+			"custom range",
+			TimeRange{Period: PeriodCustom, Start: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2024, 8, 31, 0, 0, 0, 0, time.UTC)},
+			"2024-08-01to2024-08-31",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := serveJSONFixture(t, "/res/v1/web/search", "brave.json", func(r *http.Request, _ map[string]interface{}) {
+				query := r.URL.Query()
+				if query.Get("q") != "go iterators" || query.Get("count") != "10" || query.Get("extra_snippets") != "true" {
+					t.Errorf("query = %v", query)
+				}
+				if got := query.Get("freshness"); got != tt.freshness {
+					t.Errorf("freshness = %q, want %q", got, tt.freshness)
+				}
+				if got := r.Header.Get("X-Subscription-Token"); got != "brave-test" {
+					t.Errorf("X-Subscription-Token = %q", got)
+				}
+			})
+			t.Setenv("SEARCH_SERVICE", "brave")
+			t.Setenv("BRAVE_BASE_URL", baseURL)
+			t.Setenv("BRAVE_KEY", "brave-test")
+			t.Setenv("MAX_RESULTS", "10")
+
+			results, err := SearchResults("go iterators", SearchOptions{TimeRange: tt.timeRange})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 2 {
+				t.Fatalf("got %d results, want 2", len(results))
+			}
+
+			first := results[0]
+			if first.Title != "Go Wiki: Rangefunc Experiment" {
+				t.Errorf("Title = %q, want the highlight tags removed", first.Title)
+			}
+			if first.Snippet != `Range over functions lets iterators be written as "push" functions.` {
+				t.Errorf("Snippet = %q", first.Snippet)
+			}
+			if first.Content != "Iterators are functions.\nyield returns false to stop." {
+				t.Errorf("Content = %q, want the extra snippets", first.Content)
+			}
+			if !strings.HasPrefix(first.PublishedAt, "2024-08-13") {
+				t.Errorf("PublishedAt = %q, want page_age", first.PublishedAt)
+			}
+			if first.Source != "Go" || first.Favicon != "https://imgs.search.brave.com/go.png" ||
+				first.Thumbnail != "https://imgs.search.brave.com/thumb.png" || first.Language != "en" {
+				t.Errorf("first result = %+v", first)
+			}
+			// Without page_age the relative age is used
+			if results[1].PublishedAt == "" {
+				t.Error("second result has no PublishedAt")
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Code search sources
+const (
+	CodeSourceGitHubCode    = "github_code"
+	CodeSourceGitHubIssues  = "github_issues"
+	CodeSourceGitHubRepos   = "github_repos"
+	CodeSourceStackExchange = "stackexchange"
+)
+
+// Lengths of the text kept from issue, question and answer bodies
+const (
+	codeBodyLength   = 500
+	codeAnswerLength = 1500
+)
+
+// CodeResult is a single code, issue, repository or Q&A result
+type CodeResult struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Snippet holds matched code fragments, an issue or question body, or a repository description
+	Snippet string `json:"snippet,omitempty"`
+	Source  string `json:"source"`
+	// Repository and Path locate GitHub results
+	Repository string `json:"repository,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Stars      int    `json:"stars,omitempty"`
+	// State is open or closed for issues and pull requests
+	State     string   `json:"state,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	Score     int      `json:"score,omitempty"`
+	UpdatedAt string   `json:"updated_at,omitempty"`
+	// Answer is the accepted answer of a Stack Exchange question
+	Answer string `json:"answer,omitempty"`
+}
+
+// CodeOptions narrows a code search
+type CodeOptions struct {
+	// Language filters by programming language, or by tag on Stack Exchange
+	Language string
+	// Repository limits GitHub searches to owner/name
+	Repository string
+}
+
+// CodeResults searches GitHub or Stack Exchange
+func CodeResults(query, source string, opts CodeOptions) ([]CodeResult, error) {
+	fmt.Printf("正在搜索代码: %s (%s)\n", query, source)
+
+	var results []CodeResult
+	var err error
+
+	switch source {
+	case CodeSourceGitHubCode, CodeSourceGitHubIssues, CodeSourceGitHubRepos:
+		results, err = codeWithGitHub(query, source, opts)
+	case CodeSourceStackExchange:
+		results, err = codeWithStackExchange(query, opts)
+	default:
+		return nil, fmt.Errorf("不支持的代码搜索来源: %s", source)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("代码搜索失败: %v", err)
+	}
+
+	fmt.Println("代码搜索完成")
+	return results[:min(len(results), MaxResults())], nil
+}
+
+// githubAPIURL returns the GitHub API root; GitHub Enterprise uses https://host/api/v3
+func githubAPIURL() string {
+	if value := os.Getenv("GITHUB_API_URL"); value != "" {
+		return strings.TrimSuffix(value, "/")
+	}
+	return "https://api.github.com"
+}
+
+func codeWithGitHub(query, source string, opts CodeOptions) ([]CodeResult, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if source == CodeSourceGitHubCode && token == "" {
+		return nil, fmt.Errorf("GitHub 代码搜索需要配置 GITHUB_TOKEN")
+	}
+
+	if opts.Language != "" {
+		query += " language:" + opts.Language
+	}
+	if opts.Repository != "" {
+		query += " repo:" + opts.Repository
+	}
+
+	endpoint := map[string]string{
+		CodeSourceGitHubCode:   "code",
+		CodeSourceGitHubIssues: "issues",
+		CodeSourceGitHubRepos:  "repositories",
+	}[source]
+	apiURL := fmt.Sprintf("%s/search/%s?q=%s&per_page=%d", githubAPIURL(), endpoint, url.QueryEscape(query), MaxResults())
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The text-match media type adds the matched fragments to code results
+	req.Header.Set("Accept", "application/vnd.github.text-match+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var ghErr struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&ghErr)
+		return nil, fmt.Errorf("状态码: %d %s", resp.StatusCode, ghErr.Message)
+	}
+
+	var ghResp struct {
+		Items []struct {
+			// Code results
+			Path       string `json:"path"`
+			Repository struct {
+				FullName string `json:"full_name"`
+			} `json:"repository"`
+			TextMatches []struct {
+				Fragment string `json:"fragment"`
+			} `json:"text_matches"`
+			// Issue results
+			Title         string    `json:"title"`
+			Body          string    `json:"body"`
+			State         string    `json:"state"`
+			RepositoryURL string    `json:"repository_url"`
+			PullRequest   *struct{} `json:"pull_request"`
+			Labels        []struct {
+				Name string `json:"name"`
+			} `json:"labels"`
+			// Repository results
+			FullName    string   `json:"full_name"`
+			Description string   `json:"description"`
+			Stars       int      `json:"stargazers_count"`
+			Language    string   `json:"language"`
+			Topics      []string `json:"topics"`
+
+			HTMLURL   string `json:"html_url"`
+			UpdatedAt string `json:"updated_at"`
+		} `json:"items"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&ghResp); err != nil {
+		return nil, err
+	}
+
+	var results []CodeResult
+	for _, item := range ghResp.Items {
+		result := CodeResult{
+			URL:       item.HTMLURL,
+			Source:    source,
+			UpdatedAt: item.UpdatedAt,
+		}
+		switch source {
+		case CodeSourceGitHubCode:
+			result.Title = item.Repository.FullName + "/" + item.Path
+			result.Repository = item.Repository.FullName
+			result.Path = item.Path
+			var fragments []string
+			for _, match := range item.TextMatches {
+				fragments = append(fragments, match.Fragment)
+			}
+			result.Snippet = strings.Join(fragments, "\n…\n")
+		case CodeSourceGitHubIssues:
+			result.Title = item.Title
+			if _, repo, ok := strings.Cut(item.RepositoryURL, "/repos/"); ok {
+				result.Repository = repo
+			}
+			result.Snippet = TruncateText(item.Body, codeBodyLength)
+			result.State = item.State
+			if item.PullRequest != nil {
+				result.Tags = append(result.Tags, "pull_request")
+			}
+			for _, label := range item.Labels {
+				result.Tags = append(result.Tags, label.Name)
+			}
+		case CodeSourceGitHubRepos:
+			result.Title = item.FullName
+			result.Repository = item.FullName
+			result.Snippet = item.Description
+			result.Stars = item.Stars
+			result.Language = item.Language
+			result.Tags = item.Topics
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// stackExchangeRequest calls a Stack Exchange API method and decodes its items
+func stackExchangeRequest(method string, params url.Values, v interface{}) error {
+	baseURL := os.Getenv("STACKEXCHANGE_API_URL")
+	if baseURL == "" {
+		baseURL = "https://api.stackexchange.com/2.3"
+	}
+	site := os.Getenv("STACKEXCHANGE_SITE")
+	if site == "" {
+		site = "stackoverflow"
+	}
+	params.Set("site", site)
+	params.Set("filter", "withbody")
+	if key := os.Getenv("STACKEXCHANGE_KEY"); key != "" {
+		params.Set("key", key)
+	}
+
+	resp, err := getHTTPClient().Get(strings.TrimSuffix(baseURL, "/") + method + "?" + params.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var seErr struct {
+			ErrorMessage string `json:"error_message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&seErr)
+		return fmt.Errorf("状态码: %d %s", resp.StatusCode, seErr.ErrorMessage)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func codeWithStackExchange(query string, opts CodeOptions) ([]CodeResult, error) {
+	params := url.Values{
+		"q":        {query},
+		"accepted": {"True"},
+		"order":    {"desc"},
+		"sort":     {"relevance"},
+		"pagesize": {fmt.Sprintf("%d", MaxResults())},
+	}
+	if opts.Language != "" {
+		params.Set("tagged", strings.ToLower(opts.Language))
+	}
+
+	var questions struct {
+		Items []struct {
+			QuestionID       int      `json:"question_id"`
+			AcceptedAnswerID int      `json:"accepted_answer_id"`
+			Title            string   `json:"title"`
+			Link             string   `json:"link"`
+			Body             string   `json:"body"`
+			Score            int      `json:"score"`
+			Tags             []string `json:"tags"`
+			LastActivityDate int64    `json:"last_activity_date"`
+		} `json:"items"`
+	}
+	if err := stackExchangeRequest("/search/advanced", params, &questions); err != nil {
+		return nil, err
+	}
+
+	var answerIDs []string
+	for _, item := range questions.Items {
+		if item.AcceptedAnswerID > 0 {
+			answerIDs = append(answerIDs, fmt.Sprintf("%d", item.AcceptedAnswerID))
+		}
+	}
+
+	answers := make(map[int]string)
+	if len(answerIDs) > 0 {
+		var answerResp struct {
+			Items []struct {
+				AnswerID int    `json:"answer_id"`
+				Body     string `json:"body"`
+			} `json:"items"`
+		}
+		// Answers are optional extras; the questions are still useful without them
+		if err := stackExchangeRequest("/answers/"+strings.Join(answerIDs, ";"), url.Values{}, &answerResp); err == nil {
+			for _, answer := range answerResp.Items {
+				answers[answer.AnswerID] = answer.Body
+			}
+		}
+	}
+
+	var results []CodeResult
+	for _, item := range questions.Items {
+		results = append(results, CodeResult{
+			Title:     html.UnescapeString(item.Title),
+			URL:       item.Link,
+			Snippet:   TruncateText(htmlToText(item.Body), codeBodyLength),
+			Source:    CodeSourceStackExchange,
+			Tags:      item.Tags,
+			Score:     item.Score,
+			Answer:    TruncateText(htmlToText(answers[item.AcceptedAnswerID]), codeAnswerLength),
+			UpdatedAt: time.Unix(item.LastActivityDate, 0).UTC().Format(time.RFC3339),
+		})
+	}
+
+	return results, nil
+}
+
+var (
+	htmlCodeBlockStart = regexp.MustCompile(`(?i)<pre[^>]*>\s*<code[^>]*>`)
+	htmlCodeBlockEnd   = regexp.MustCompile(`(?i)</code>\s*</pre>`)
+	htmlInlineCode     = regexp.MustCompile(`(?i)</?code[^>]*>`)
+	htmlLineBreak      = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>`)
+	blankLines         = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts Stack Exchange post HTML to plain text, keeping code blocks as Markdown fences
+func htmlToText(s string) string {
+	s = htmlCodeBlockStart.ReplaceAllString(s, "\n```\n")
+	s = htmlCodeBlockEnd.ReplaceAllString(s, "\n```\n")
+	s = htmlInlineCode.ReplaceAllString(s, "`")
+	s = htmlLineBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
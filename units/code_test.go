@@ -0,0 +1,195 @@
+package units
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeResultsGitHub(t *testing.T) {
+	tests := []struct {
+		source   string
+		endpoint string
+		fixture  string
+		opts     CodeOptions
+		query    string
+		want     CodeResult
+	}{
+		{
+			source:   CodeSourceGitHubCode,
+			endpoint: "/search/code",
+			fixture:  "github_code.json",
+			opts:     CodeOptions{Language: "go", Repository: "golang/go"},
+			query:    "Seq language:go repo:golang/go",
+			want: CodeResult{
+				Title:      "golang/go/src/iter/iter.go",
+				URL:        "https://github.com/golang/go/blob/master/src/iter/iter.go",
+				Snippet:    "type Seq[V any] func(yield func(V) bool)\n…\nfunc Pull[V any](seq Seq[V])",
+				Source:     CodeSourceGitHubCode,
+				Repository: "golang/go",
+				Path:       "src/iter/iter.go",
+			},
+		},
+		{
+			source:   CodeSourceGitHubIssues,
+			endpoint: "/search/issues",
+			fixture:  "github_issues.json",
+			query:    "Seq",
+			want: CodeResult{
+				Title:      "iter: new package",
+				URL:        "https://github.com/golang/go/pull/62000",
+				Snippet:    "Adds Seq and Seq2.",
+				Source:     CodeSourceGitHubIssues,
+				Repository: "golang/go",
+				State:      "open",
+				Tags:       []string{"pull_request"},
+				UpdatedAt:  "2024-03-01T10:00:00Z",
+			},
+		},
+		{
+			source:   CodeSourceGitHubRepos,
+			endpoint: "/search/repositories",
+			fixture:  "github_repos.json",
+			opts:     CodeOptions{Language: "go"},
+			query:    "Seq language:go",
+			want: CodeResult{
+				Title:      "golang/go",
+				URL:        "https://github.com/golang/go",
+				Snippet:    "The Go programming language",
+				Source:     CodeSourceGitHubRepos,
+				Repository: "golang/go",
+				Language:   "Go",
+				Stars:      120000,
+				Tags:       []string{"go", "language"},
+				UpdatedAt:  "2024-08-01T00:00:00Z",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			srv := serveJSONFixture(t, tt.endpoint, tt.fixture, func(r *http.Request, _ map[string]interface{}) {
+				if got := r.URL.Query().Get("q"); got != tt.query {
+					t.Errorf("q = %q, want %q", got, tt.query)
+				}
+				if got := r.URL.Query().Get("per_page"); got != "5" {
+					t.Errorf("per_page = %q, want 5", got)
+				}
+				if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+					t.Errorf("Authorization = %q", got)
+				}
+				if got := r.Header.Get("Accept"); got != "application/vnd.github.text-match+json" {
+					t.Errorf("Accept = %q", got)
+				}
+			})
+			t.Setenv("GITHUB_API_URL", srv+"/")
+			t.Setenv("GITHUB_TOKEN", "test-token")
+			t.Setenv("MAX_RESULTS", "5")
+
+			results, err := CodeResults("Seq", tt.source, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) == 0 {
+				t.Fatal("no results")
+			}
+			got := results[len(results)-1]
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeResultsGitHubIssueBody(t *testing.T) {
+	srv := serveJSONFixture(t, "/search/issues", "github_issues.json", func(*http.Request, map[string]interface{}) {})
+	t.Setenv("GITHUB_API_URL", srv)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("MAX_RESULTS", "5")
+
+	results, err := CodeResults("range over func", CodeSourceGitHubIssues, CodeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	issue := results[0]
+	if n := len([]rune(issue.Snippet)); n != codeBodyLength+1 || !strings.HasSuffix(issue.Snippet, "…") {
+		t.Errorf("snippet has %d runes, want a body cut to %d runes", n, codeBodyLength)
+	}
+	if want := []string{"Proposal", "LanguageChange"}; !reflect.DeepEqual(issue.Tags, want) {
+		t.Errorf("tags = %v, want %v", issue.Tags, want)
+	}
+	if issue.State != "closed" || issue.Repository != "golang/go" {
+		t.Errorf("state = %q, repository = %q", issue.State, issue.Repository)
+	}
+}
+
+func TestCodeResultsGitHubCodeNeedsToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+
+	if _, err := CodeResults("Seq", CodeSourceGitHubCode, CodeOptions{}); err == nil || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+		t.Errorf("err = %v, want a missing GITHUB_TOKEN error", err)
+	}
+}
+
+func TestCodeResultsStackExchange(t *testing.T) {
+	questions := readFixture(t, "stackexchange_questions.json")
+	answers := readFixture(t, "stackexchange_answers.json")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("site") != "superuser" || query.Get("filter") != "withbody" || query.Get("key") != "test-key" {
+			t.Errorf("%s: site = %q, filter = %q, key = %q", r.URL.Path, query.Get("site"), query.Get("filter"), query.Get("key"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/2.3/search/advanced":
+			if query.Get("q") != "range over func" || query.Get("tagged") != "go" || query.Get("accepted") != "True" {
+				t.Errorf("search query = %v", query)
+			}
+			w.Write(questions)
+		case "/2.3/answers/201":
+			w.Write(answers)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("STACKEXCHANGE_API_URL", srv.URL+"/2.3")
+	t.Setenv("STACKEXCHANGE_SITE", "superuser")
+	t.Setenv("STACKEXCHANGE_KEY", "test-key")
+	t.Setenv("MAX_RESULTS", "5")
+
+	results, err := CodeResults("range over func", CodeSourceStackExchange, CodeOptions{Language: "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+
+	question := results[0]
+	if want := "How do I stop a range-over-func loop & clean up?"; question.Title != want {
+		t.Errorf("title = %q, want %q", question.Title, want)
+	}
+	if want := "My iterator leaks:\n\n```\nfor v := range seq {\n    break\n}\n\n```\n\nWhy is `yield` still called?"; question.Snippet != want {
+		t.Errorf("snippet = %q, want %q", question.Snippet, want)
+	}
+	if !strings.HasPrefix(question.Answer, "Check the result of `yield`:") {
+		t.Errorf("answer = %q", question.Answer)
+	}
+	if n := len([]rune(question.Answer)); n != codeAnswerLength+1 || !strings.HasSuffix(question.Answer, "…") {
+		t.Errorf("answer has %d runes, want a body cut to %d runes", n, codeAnswerLength)
+	}
+	if question.Score != 42 || question.UpdatedAt != "2024-08-01T00:00:00Z" || !reflect.DeepEqual(question.Tags, []string{"go", "iterator"}) {
+		t.Errorf("score = %d, updated = %q, tags = %v", question.Score, question.UpdatedAt, question.Tags)
+	}
+
+	if unanswered := results[1]; unanswered.Answer != "" || unanswered.Snippet != "No accepted answer yet." {
+		t.Errorf("unanswered question = %+v", unanswered)
+	}
+}
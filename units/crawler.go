@@ -4,50 +4,361 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
-// Crawler performs web crawling to extract content from a URL
-func Crawler(url string) (string, error) {
+// defaultCrawlMaxChars bounds the page content kept from a crawl
+const defaultCrawlMaxChars = 30000
+
+// CrawlResult is a crawled page in the same shape whichever crawler service fetched it
+type CrawlResult struct {
+	// URL is the requested URL and FinalURL the page actually fetched, after redirects
+	URL      string `json:"url"`
+	FinalURL string `json:"final_url,omitempty"`
+	// Status is the HTTP status of the page, for services that report it
+	Status int    `json:"status,omitempty"`
+	Title  string `json:"title,omitempty"`
+	// Content is the page text, as Markdown when the service provides it
+	Content string `json:"content"`
+	// ContentType is the media type of Content: text/markdown for the crawl
+	// services, application/pdf for PDFs extracted locally, or whatever a
+	// self-hosted service reports
+	ContentType string `json:"content_type,omitempty"`
+	// Language is the page language, such as en or zh-CN. It is provider-dependent:
+	// Firecrawl reads it from <html lang>, a self-hosted service may report it, and
+	// it is omitted for Search1API, Jina and PDFs.
+	Language  string `json:"language,omitempty"`
+	FetchedAt string `json:"fetched_at"`
+	// Truncated reports whether Content was cut to CRAWLER_MAX_CHARS or PDF_MAX_CHARS
+	Truncated bool `json:"truncated"`
+	// Links lists the URLs linked from the page, for services that report them
+	Links []string `json:"links,omitempty"`
+	// Metadata holds other page details reported by the service, such as the description
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// CrawlerServices lists the supported crawler services
+var CrawlerServices = []string{"search1api", "jina", "firecrawl", "selfhosted"}
+
+// IsCrawlerService reports whether service names a supported crawler service
+func IsCrawlerService(service string) bool {
+	for _, name := range CrawlerServices {
+		if service == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Crawl extracts the content of a URL with a crawler service. An empty service
+// uses CRAWLER_SERVICE, which defaults to search1api.
+func Crawl(url, service string) (*CrawlResult, error) {
 	fmt.Printf("正在使用 URL 进行自定义爬取:%s\n", url)
 
-	reqBody := map[string]string{
-		"url": url,
+	fetchedAt := time.Now().UTC().Format(time.RFC3339)
+
+	// PDFs are parsed locally; if no text can be extracted the crawl service gets a try
+	if isPDFURL(url) {
+		result, err := crawlPDF(url)
+		if err == nil {
+			fmt.Println("PDF文本提取完成")
+			result.FetchedAt = fetchedAt
+			return result, nil
+		}
+		log.Printf("PDF文本提取失败，改用爬虫服务: %v", err)
+	}
+
+	if service == "" {
+		service = os.Getenv("CRAWLER_SERVICE")
+	}
+	if service == "" {
+		service = "search1api"
+	}
+
+	var result *CrawlResult
+	var err error
+
+	switch service {
+	case "search1api":
+		result, err = crawlWithSearch1API(url)
+	case "jina":
+		result, err = crawlWithJina(url)
+	case "firecrawl":
+		result, err = crawlWithFirecrawl(url)
+	case "selfhosted":
+		result, err = crawlWithSelfHosted(url)
+	default:
+		return nil, fmt.Errorf("不支持的爬虫服务: %s", service)
 	}
 
-	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("JSON编码失败: %v", err)
+		return nil, err
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return nil, fmt.Errorf("爬虫服务未返回网页内容")
+	}
+
+	result.URL = url
+	result.FetchedAt = fetchedAt
+	maxChars := parseInt(os.Getenv("CRAWLER_MAX_CHARS"))
+	if maxChars <= 0 {
+		maxChars = defaultCrawlMaxChars
 	}
+	if runes := []rune(result.Content); len(runes) > maxChars {
+		result.Content = string(runes[:maxChars])
+		result.Truncated = true
+	}
+
+	fmt.Println("自定义爬取服务调用完成")
+	return result, nil
+}
 
-	resp, err := http.Post("https://crawl.search1api.com", "application/json", bytes.NewBuffer(jsonData))
+// Crawler crawls a URL with CRAWLER_SERVICE and returns the result as JSON
+//
+// Deprecated: use Crawl, which returns a CrawlResult.
+func Crawler(url string) (string, error) {
+	result, err := Crawl(url, "")
 	if err != nil {
-		return "", fmt.Errorf("API请求失败: %v", err)
+		return "", err
+	}
+
+	responseData, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("响应JSON编码失败: %v", err)
+	}
+	return string(responseData), nil
+}
+
+// Markdown renders the page compactly for the model: title, address and content
+func (r *CrawlResult) Markdown() string {
+	var sb strings.Builder
+	if r.Title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", r.Title)
+	}
+	link := r.FinalURL
+	if link == "" {
+		link = r.URL
+	}
+	sb.WriteString(link)
+	if r.Status != 0 && r.Status != http.StatusOK {
+		fmt.Fprintf(&sb, "（状态码 %d）", r.Status)
+	}
+	sb.WriteString("\n\n")
+	sb.WriteString(strings.TrimSpace(r.Content))
+	if r.Truncated {
+		sb.WriteString("\n\n（内容过长，已截断）")
+	}
+	return sb.String()
+}
+
+// postCrawlJSON posts a JSON body to a crawl service and decodes its JSON response
+func postCrawlJSON(apiURL, apiKey string, body interface{}, v interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("JSON编码失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("API请求失败: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	return doCrawlRequest(req, v)
+}
+
+// doCrawlRequest sends a crawl request and decodes its JSON response
+func doCrawlRequest(req *http.Request, v interface{}) error {
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("API请求失败: %v", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API请求失败, 状态码: %d", resp.StatusCode)
+		return fmt.Errorf("API请求失败, 状态码: %d", resp.StatusCode)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
-		return "", fmt.Errorf("收到的响应不是有效的JSON格式")
+		return fmt.Errorf("收到的响应不是有效的JSON格式")
 	}
 
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("JSON解码失败: %v", err)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("JSON解码失败: %v", err)
 	}
+	return nil
+}
 
-	fmt.Println("自定义爬取服务调用完成")
+func crawlWithSearch1API(url string) (*CrawlResult, error) {
+	apiURL := os.Getenv("CRAWLER_API_URL")
+	if apiURL == "" {
+		apiURL = "https://crawl.search1api.com"
+	}
 
-	// Convert the result back to JSON string
-	responseData, err := json.Marshal(result)
+	var crawlResp struct {
+		Results struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+	if err := postCrawlJSON(apiURL, os.Getenv("SEARCH1API_KEY"), map[string]string{"url": url}, &crawlResp); err != nil {
+		return nil, err
+	}
+
+	return &CrawlResult{
+		FinalURL:    crawlResp.Results.Link,
+		Title:       crawlResp.Results.Title,
+		Content:     crawlResp.Results.Content,
+		ContentType: "text/markdown",
+	}, nil
+}
+
+func crawlWithJina(url string) (*CrawlResult, error) {
+	baseURL := os.Getenv("JINA_READER_URL")
+	if baseURL == "" {
+		baseURL = "https://r.jina.ai"
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(baseURL, "/")+"/"+url, nil)
 	if err != nil {
-		return "", fmt.Errorf("响应JSON编码失败: %v", err)
+		return nil, fmt.Errorf("API请求失败: %v", err)
 	}
 
-	return string(responseData), nil
+	req.Header.Set("Accept", "application/json")
+	// Adds a map of link text to URL to the response
+	req.Header.Set("X-With-Links-Summary", "true")
+	if apiKey := os.Getenv("JINA_KEY"); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	var jinaResp struct {
+		Data struct {
+			Title         string            `json:"title"`
+			URL           string            `json:"url"`
+			Content       string            `json:"content"`
+			Description   string            `json:"description"`
+			PublishedTime string            `json:"publishedTime"`
+			Links         map[string]string `json:"links"`
+		} `json:"data"`
+	}
+	if err := doCrawlRequest(req, &jinaResp); err != nil {
+		return nil, err
+	}
+
+	data := jinaResp.Data
+	result := &CrawlResult{
+		FinalURL:    data.URL,
+		Title:       data.Title,
+		Content:     data.Content,
+		ContentType: "text/markdown",
+		Metadata:    make(map[string]string),
+	}
+	for _, link := range data.Links {
+		result.Links = append(result.Links, link)
+	}
+	sort.Strings(result.Links)
+	if data.Description != "" {
+		result.Metadata["description"] = data.Description
+	}
+	if data.PublishedTime != "" {
+		result.Metadata["published_time"] = data.PublishedTime
+	}
+	return result, nil
+}
+
+func crawlWithFirecrawl(url string) (*CrawlResult, error) {
+	baseURL := os.Getenv("FIRECRAWL_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://api.firecrawl.dev"
+	}
+
+	reqBody := map[string]interface{}{
+		"url":             url,
+		"formats":         []string{"markdown", "links"},
+		"onlyMainContent": true,
+	}
+
+	var firecrawlResp struct {
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+		Data    struct {
+			Markdown string                 `json:"markdown"`
+			Links    []string               `json:"links"`
+			Metadata map[string]interface{} `json:"metadata"`
+		} `json:"data"`
+	}
+	if err := postCrawlJSON(strings.TrimSuffix(baseURL, "/")+"/v1/scrape", os.Getenv("FIRECRAWL_KEY"), reqBody, &firecrawlResp); err != nil {
+		return nil, err
+	}
+	if !firecrawlResp.Success {
+		return nil, fmt.Errorf("Firecrawl 抓取失败: %s", firecrawlResp.Error)
+	}
+
+	data := firecrawlResp.Data
+	result := &CrawlResult{
+		FinalURL:    firstString(data.Metadata, "url", "sourceURL"),
+		Title:       firstString(data.Metadata, "title", "ogTitle"),
+		Content:     data.Markdown,
+		ContentType: "text/markdown",
+		Language:    firstString(data.Metadata, "language"),
+		Links:       data.Links,
+		Metadata:    make(map[string]string),
+	}
+	if status, ok := data.Metadata["statusCode"].(float64); ok {
+		result.Status = int(status)
+	}
+	// Only scalar metadata is kept; Firecrawl repeats some meta tags as arrays
+	for key, value := range data.Metadata {
+		switch key {
+		case "url", "sourceURL", "title", "language", "statusCode":
+			continue
+		}
+		switch value := value.(type) {
+		case string:
+			result.Metadata[key] = value
+		case float64, bool:
+			result.Metadata[key] = fmt.Sprint(value)
+		}
+	}
+	return result, nil
+}
+
+// crawlWithSelfHosted calls a crawl service that takes {"url": ...} and answers
+// with the CrawlResult fields; "markdown" is accepted in place of "content"
+func crawlWithSelfHosted(url string) (*CrawlResult, error) {
+	apiURL := os.Getenv("SELFHOSTED_CRAWLER_URL")
+	if apiURL == "" {
+		return nil, fmt.Errorf("未配置 SELFHOSTED_CRAWLER_URL")
+	}
+
+	var crawlResp struct {
+		CrawlResult
+		Markdown string `json:"markdown"`
+	}
+	if err := postCrawlJSON(apiURL, os.Getenv("SELFHOSTED_CRAWLER_KEY"), map[string]string{"url": url}, &crawlResp); err != nil {
+		return nil, err
+	}
+
+	result := crawlResp.CrawlResult
+	if result.ContentType == "" {
+		result.ContentType = "text/plain"
+		if result.Content == "" {
+			result.ContentType = "text/markdown"
+		}
+	}
+	if result.Content == "" {
+		result.Content = crawlResp.Markdown
+	}
+	return &result, nil
 }
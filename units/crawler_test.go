@@ -0,0 +1,109 @@
+package units
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlServices(t *testing.T) {
+	const pageURL = "https://go.dev/doc/go1.23"
+
+	tests := []struct {
+		service string
+		path    string
+		fixture string
+		env     string
+		// suffix is appended to the server URL in env
+		suffix      string
+		title       string
+		contentType string
+		language    string
+		links       []string
+	}{
+		{
+			service:     "search1api",
+			path:        "/crawl",
+			fixture:     "crawl_search1api.json",
+			env:         "CRAWLER_API_URL",
+			suffix:      "/crawl",
+			title:       "Go 1.23 Release Notes",
+			contentType: "text/markdown",
+		},
+		{
+			service:     "jina",
+			path:        "/" + pageURL,
+			fixture:     "crawl_jina.json",
+			env:         "JINA_READER_URL",
+			title:       "Go 1.23 Release Notes",
+			contentType: "text/markdown",
+			links:       []string{"https://go.dev/dl/", "https://go.dev/doc/go1.22"},
+		},
+		{
+			service:     "firecrawl",
+			path:        "/v1/scrape",
+			fixture:     "crawl_firecrawl.json",
+			env:         "FIRECRAWL_BASE_URL",
+			title:       "Go 1.23 Release Notes - The Go Programming Language",
+			contentType: "text/markdown",
+			language:    "en",
+			links:       []string{"https://go.dev/doc/go1.22", "https://go.dev/dl/"},
+		},
+		{
+			service:     "selfhosted",
+			path:        "/crawl",
+			fixture:     "crawl_selfhosted.json",
+			env:         "SELFHOSTED_CRAWLER_URL",
+			suffix:      "/crawl",
+			title:       "Go 1.23 发布说明",
+			contentType: "text/markdown",
+			language:    "zh-CN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.service, func(t *testing.T) {
+			srv := serveJSONFixture(t, tt.path, tt.fixture, func(*http.Request, map[string]interface{}) {})
+			t.Setenv(tt.env, srv+tt.suffix)
+			t.Setenv("CRAWLER_MAX_CHARS", "")
+
+			result, err := Crawl(pageURL, tt.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.URL != pageURL || result.FinalURL != pageURL || result.Title != tt.title || result.Content == "" {
+				t.Errorf("result = %+v", result)
+			}
+			if result.ContentType != tt.contentType {
+				t.Errorf("ContentType = %q, want %q", result.ContentType, tt.contentType)
+			}
+			if result.Language != tt.language {
+				t.Errorf("Language = %q, want %q", result.Language, tt.language)
+			}
+			if !reflect.DeepEqual(result.Links, tt.links) {
+				t.Errorf("Links = %q, want %q", result.Links, tt.links)
+			}
+		})
+	}
+}
+
+func TestCrawlerJSON(t *testing.T) {
+	srv := serveJSONFixture(t, "/crawl", "crawl_search1api.json", func(*http.Request, map[string]interface{}) {})
+	t.Setenv("CRAWLER_SERVICE", "")
+	t.Setenv("CRAWLER_API_URL", srv+"/crawl")
+
+	data, err := Crawler("https://go.dev/doc/go1.23")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result CrawlResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Title != "Go 1.23 Release Notes" || result.ContentType != "text/markdown" || result.FetchedAt == "" {
+		t.Errorf("result = %+v", result)
+	}
+}
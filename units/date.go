@@ -0,0 +1,126 @@
+package units
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayouts lists the absolute date formats returned by search providers
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.0000000",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"02 Jan 2006",
+	"Mon, 02 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04:05 -0700",
+	"01/02/2006",
+	// Formats used by Chinese providers; "1" and "2" accept one or two digits
+	"2006-01-02 15:04",
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+	"2006/1/2",
+	"2006.1.2",
+	"2006年1月2日 15:04:05",
+	"2006年1月2日 15:04",
+	"2006年1月2日",
+	"2006年1月",
+}
+
+// relativeDatePattern matches dates such as "3 days ago" or "1 hour ago"
+var relativeDatePattern = regexp.MustCompile(`^(\d+)\s+(second|minute|hour|day|week|month|year)s?\s+ago$`)
+
+// chineseRelativeDatePattern matches dates such as "3天前" or "1个月前"
+var chineseRelativeDatePattern = regexp.MustCompile(`^(\d+)\s*(秒|分钟|小时|天|周|个月|月|年)前$`)
+
+// chineseRelativeUnits maps Chinese relative date units to their English names
+var chineseRelativeUnits = map[string]string{
+	"秒":  "second",
+	"分钟": "minute",
+	"小时": "hour",
+	"天":  "day",
+	"周":  "week",
+	"个月": "month",
+	"月":  "month",
+	"年":  "year",
+}
+
+// chinaTime is the zone of dates without an offset from Chinese providers
+var chinaTime = time.FixedZone("CST", 8*60*60)
+
+// ParseDate parses an absolute or relative date as reported by a provider
+func ParseDate(s string) (time.Time, bool) {
+	return parseDateAt(s, time.Now(), time.UTC)
+}
+
+// chinaDate normalises a date from a Chinese provider to RFC 3339, reading
+// dates without an offset as China Standard Time
+func chinaDate(s string) string {
+	if t, ok := parseDateAt(s, time.Now(), chinaTime); ok {
+		return t.Format(time.RFC3339)
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseDateAt parses s relative to now; loc is the zone of dates without an offset
+func parseDateAt(s string, now time.Time, loc *time.Location) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, true
+		}
+	}
+
+	switch s {
+	case "刚刚", "今天":
+		return now, true
+	case "昨天":
+		return now.AddDate(0, 0, -1), true
+	case "前天":
+		return now.AddDate(0, 0, -2), true
+	}
+	if m := chineseRelativeDatePattern.FindStringSubmatch(s); m != nil {
+		s = m[1] + " " + chineseRelativeUnits[m[2]] + " ago"
+	}
+
+	if m := relativeDatePattern.FindStringSubmatch(strings.ToLower(s)); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "second":
+			return now.Add(-time.Duration(n) * time.Second), true
+		case "minute":
+			return now.Add(-time.Duration(n) * time.Minute), true
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), true
+		case "day":
+			return now.AddDate(0, 0, -n), true
+		case "week":
+			return now.AddDate(0, 0, -7*n), true
+		case "month":
+			return now.AddDate(0, -n, 0), true
+		case "year":
+			return now.AddDate(-n, 0, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeDate converts a provider date to RFC 3339, keeping the original
+// text when it cannot be parsed
+func normalizeDate(s string) string {
+	if t, ok := ParseDate(s); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,110 @@
+package units
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxDomainOperators bounds the site: operators added to a query, since
+// providers limit query length; post-filtering enforces the rest
+const maxDomainOperators = 10
+
+// normalizeDomain reduces a domain or URL to a bare lowercase host name
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if strings.Contains(domain, "://") {
+		if u, err := url.Parse(domain); err == nil {
+			domain = u.Hostname()
+		}
+	}
+	domain = strings.SplitN(domain, "/", 2)[0]
+	return strings.TrimPrefix(domain, "www.")
+}
+
+// normalizeDomains normalises a list of domains, dropping empty and duplicate entries
+func normalizeDomains(domains []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		d := normalizeDomain(domain)
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		normalized = append(normalized, d)
+	}
+	return normalized
+}
+
+// blockedDomains returns the domains configured in BLOCKED_DOMAINS
+func blockedDomains() []string {
+	value := os.Getenv("BLOCKED_DOMAINS")
+	if value == "" {
+		return nil
+	}
+	return normalizeDomains(strings.Split(value, ","))
+}
+
+// withDomainOperators adds site: and -site: operators to a query
+func withDomainOperators(query string, include, exclude []string) string {
+	operators := 0
+	if len(include) > 0 {
+		var sites []string
+		for _, domain := range include {
+			if operators >= maxDomainOperators {
+				break
+			}
+			sites = append(sites, "site:"+domain)
+			operators++
+		}
+		if len(sites) == 1 {
+			query += " " + sites[0]
+		} else {
+			query += " (" + strings.Join(sites, " OR ") + ")"
+		}
+	}
+	for _, domain := range exclude {
+		if operators >= maxDomainOperators {
+			break
+		}
+		query += " -site:" + domain
+		operators++
+	}
+	return query
+}
+
+// matchesDomain reports whether host is domain or one of its subdomains
+func matchesDomain(host string, domains []string) bool {
+	host = normalizeDomain(host)
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterByDomain keeps results from the included domains, when any are given,
+// and drops results from excluded domains
+func filterByDomain(results []SearchResult, include, exclude []string) []SearchResult {
+	if len(include) == 0 && len(exclude) == 0 {
+		return results
+	}
+
+	var filtered []SearchResult
+	for _, result := range results {
+		u, err := url.Parse(result.Link)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		if len(include) > 0 && !matchesDomain(u.Hostname(), include) {
+			continue
+		}
+		if matchesDomain(u.Hostname(), exclude) {
+			continue
+		}
+		filtered = append(filtered, result)
+	}
+	return filtered
+}
@@ -0,0 +1,331 @@
+package units
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseDuckDuckGo(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint duckDuckGoEndpoint
+		fixture  string
+		want     []SearchResult
+	}{
+		{
+			name:     "html",
+			endpoint: duckDuckGoHTML,
+			fixture:  "duckduckgo_html.html",
+			want: []SearchResult{
+				{
+					Title:       "Range Over Function Types - The Go Programming Language",
+					Link:        "https://go.dev/blog/range-functions",
+					Snippet:     "This is the blog post version of my talk on iterators & range over function types.",
+					PublishedAt: "2024-08-20T00:00:00.0000000",
+				},
+				{
+					Title:   "iter package - iter - Go Packages",
+					Link:    "https://pkg.go.dev/iter?tab=doc&x=1",
+					Snippet: "Package iter provides basic definitions and operations related to iterators over sequences.",
+				},
+				{
+					Title:   "Go Iterators Tutorial",
+					Link:    "https://www.example.org/go-iterators-tutorial",
+					Snippet: "A step-by-step tutorial.",
+				},
+			},
+		},
+		{
+			name:     "lite",
+			endpoint: duckDuckGoLite,
+			fixture:  "duckduckgo_lite.html",
+			want: []SearchResult{
+				{
+					Title:       "Range Over Function Types - The Go Programming Language",
+					Link:        "https://go.dev/blog/range-functions",
+					Snippet:     "This is the blog post version of my talk on iterators.",
+					PublishedAt: "2024-08-20T00:00:00.0000000",
+				},
+				{
+					Title:   "iter package - iter - Go Packages",
+					Link:    "https://pkg.go.dev/iter",
+					Snippet: "Package iter provides basic definitions & operations.",
+				},
+			},
+		},
+		{
+			name:     "challenge page",
+			endpoint: duckDuckGoHTML,
+			fixture:  "duckduckgo_challenge.html",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDuckDuckGo(tt.endpoint, string(readFixture(t, tt.fixture)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDuckDuckGo() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuckDuckGoLink(t *testing.T) {
+	tests := []struct {
+		href string
+		want string
+		ok   bool
+	}{
+		{"//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc%2F&amp;rut=abc", "https://go.dev/doc/", true},
+		{"https://duckduckgo.com/l?uddg=http%3A%2F%2Fexample.com%2Fa%3Fb%3Dc", "http://example.com/a?b=c", true},
+		{"https://www.example.org/page", "https://www.example.org/page", true},
+		// Ads are routed through y.js
+		{"https://duckduckgo.com/y.js?ad_domain=example.com&amp;u3=https%3A%2F%2Fwww.bing.com", "", false},
+		{"//duckduckgo.com/?q=go+iterators&amp;ia=web", "", false},
+		{"https://html.duckduckgo.com/html/?q=go", "", false},
+		{"//duckduckgo.com/l/?uddg=javascript%3Aalert(1)", "", false},
+		{"/feedback.html", "", false},
+		{"mailto:someone@example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := duckDuckGoLink(tt.href)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("duckDuckGoLink(%q) = %q, %v, want %q, %v", tt.href, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDuckDuckGoNextPage(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    url.Values
+	}{
+		{
+			fixture: "duckduckgo_html.html",
+			want: url.Values{
+				"q": {"go iterators"}, "s": {"10"}, "nextParams": {""}, "v": {"l"}, "o": {"json"},
+				"dc": {"11"}, "api": {"d.js"}, "vqd": {"4-2112&8913"}, "kl": {"wt-wt"},
+			},
+		},
+		{
+			fixture: "duckduckgo_lite.html",
+			want: url.Values{
+				"q": {"go iterators"}, "s": {"23"}, "o": {"json"}, "dc": {"24"}, "api": {"d.js"}, "kl": {"wt-wt"},
+			},
+		},
+		// The last page only has a "Previous" form
+		{fixture: "duckduckgo_html_last.html", want: nil},
+		{fixture: "duckduckgo_challenge.html", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got := duckDuckGoNextPage(string(readFixture(t, tt.fixture)))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("duckDuckGoNextPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// duckDuckGoServer fakes the html and lite result pages. pages maps a path
+// to a function choosing the status and fixture for a posted form; the forms
+// received per path are recorded.
+type duckDuckGoServer struct {
+	mu    sync.Mutex
+	forms map[string][]url.Values
+}
+
+func serveDuckDuckGo(t *testing.T, pages map[string]func(form url.Values) (int, string)) *duckDuckGoServer {
+	t.Helper()
+	s := &duckDuckGoServer{forms: make(map[string][]url.Values)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Path]
+		if !ok || r.Method != "POST" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.forms[r.URL.Path] = append(s.forms[r.URL.Path], r.PostForm)
+		s.mu.Unlock()
+
+		status, fixture := page(r.PostForm)
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(status)
+		w.Write(readFixture(t, fixture))
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("SEARCH_SERVICE", "duckduckgo")
+	t.Setenv("DUCKDUCKGO_HTML_URL", srv.URL+"/html/")
+	t.Setenv("DUCKDUCKGO_LITE_URL", srv.URL+"/lite/")
+	t.Setenv("DUCKDUCKGO_BACKEND", "")
+	t.Setenv("DUCKDUCKGO_REGION", "")
+	t.Setenv("DUCKDUCKGO_SAFESEARCH", "")
+	t.Setenv("BLOCKED_DOMAINS", "")
+	t.Setenv("RERANK", "")
+	return s
+}
+
+// htmlPages serves the first result page, then the last one for the next-page form
+func htmlPages(form url.Values) (int, string) {
+	if form.Get("s") == "10" {
+		return http.StatusOK, "duckduckgo_html_last.html"
+	}
+	return http.StatusOK, "duckduckgo_html.html"
+}
+
+func litePage(url.Values) (int, string) {
+	return http.StatusOK, "duckduckgo_lite.html"
+}
+
+func challengePage(url.Values) (int, string) {
+	return http.StatusOK, "duckduckgo_challenge.html"
+}
+
+func rateLimited(url.Values) (int, string) {
+	return http.StatusAccepted, "duckduckgo_challenge.html"
+}
+
+func resultLinks(results []SearchResult) []string {
+	var links []string
+	for _, result := range results {
+		links = append(links, result.Link)
+	}
+	return links
+}
+
+func TestSearchWithDuckDuckGoPagination(t *testing.T) {
+	server := serveDuckDuckGo(t, map[string]func(url.Values) (int, string){"/html/": htmlPages, "/lite/": litePage})
+	t.Setenv("DUCKDUCKGO_REGION", "us-en")
+	t.Setenv("DUCKDUCKGO_SAFESEARCH", "strict")
+	t.Setenv("MAX_RESULTS", "4")
+
+	results, err := SearchResults("go iterators", SearchOptions{TimeRange: TimeRange{Period: PeriodWeek}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repeated result on the last page is dropped
+	wantLinks := []string{
+		"https://go.dev/blog/range-functions",
+		"https://pkg.go.dev/iter?tab=doc&x=1",
+		"https://www.example.org/go-iterators-tutorial",
+		"https://go.dev/doc/go1.23",
+	}
+	if links := resultLinks(results); !reflect.DeepEqual(links, wantLinks) {
+		t.Errorf("links = %q, want %q", links, wantLinks)
+	}
+	if results[0].Provider != "duckduckgo" || !strings.HasPrefix(results[0].PublishedAt, "2024-08-20") {
+		t.Errorf("first result = %+v", results[0])
+	}
+
+	forms := server.forms["/html/"]
+	if len(forms) != 2 || len(server.forms["/lite/"]) != 0 {
+		t.Fatalf("requests: html %d, lite %d, want 2 and 0", len(forms), len(server.forms["/lite/"]))
+	}
+	// The next-page form is posted back, with the filters set on every page
+	if next := forms[1]; next.Get("s") != "10" || next.Get("vqd") != "4-2112&8913" {
+		t.Errorf("second page form = %v", next)
+	}
+	for i, form := range forms {
+		if form.Get("q") != "go iterators" || form.Get("kl") != "us-en" || form.Get("kp") != "1" || form.Get("df") != "w" {
+			t.Errorf("page %d form = %v", i+1, form)
+		}
+	}
+}
+
+func TestSearchWithDuckDuckGoFallback(t *testing.T) {
+	liteLinks := []string{"https://go.dev/blog/range-functions", "https://pkg.go.dev/iter"}
+
+	tests := []struct {
+		name    string
+		backend string
+		html    func(url.Values) (int, string)
+		lite    func(url.Values) (int, string)
+		links   []string
+		wantErr string
+		// requests is the number of html and lite pages requested
+		requests [2]int
+	}{
+		{
+			name:     "one html page fills MAX_RESULTS",
+			html:     htmlPages,
+			lite:     litePage,
+			links:    []string{"https://go.dev/blog/range-functions", "https://pkg.go.dev/iter?tab=doc&x=1"},
+			requests: [2]int{1, 0},
+		},
+		{
+			name:     "challenge page falls back to lite",
+			html:     challengePage,
+			lite:     litePage,
+			links:    liteLinks,
+			requests: [2]int{1, 1},
+		},
+		{
+			name:     "rate limited html falls back to lite",
+			html:     rateLimited,
+			lite:     litePage,
+			links:    liteLinks,
+			requests: [2]int{1, 1},
+		},
+		{
+			name:     "lite backend",
+			backend:  "lite",
+			html:     htmlPages,
+			lite:     litePage,
+			links:    liteLinks,
+			requests: [2]int{0, 1},
+		},
+		{
+			name:     "both pages challenged",
+			html:     challengePage,
+			lite:     challengePage,
+			wantErr:  "人机验证",
+			requests: [2]int{1, 1},
+		},
+		{
+			name:     "lite backend challenged",
+			backend:  "lite",
+			html:     htmlPages,
+			lite:     rateLimited,
+			wantErr:  "状态码: 202",
+			requests: [2]int{0, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := serveDuckDuckGo(t, map[string]func(url.Values) (int, string){"/html/": tt.html, "/lite/": tt.lite})
+			t.Setenv("DUCKDUCKGO_BACKEND", tt.backend)
+			t.Setenv("MAX_RESULTS", "2")
+
+			results, err := SearchResults("go iterators", SearchOptions{})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if links := resultLinks(results); !reflect.DeepEqual(links, tt.links) {
+				t.Errorf("links = %q, want %q", links, tt.links)
+			}
+			if requests := [2]int{len(server.forms["/html/"]), len(server.forms["/lite/"])}; requests != tt.requests {
+				t.Errorf("requests = %v, want %v", requests, tt.requests)
+			}
+		})
+	}
+}
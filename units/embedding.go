@@ -0,0 +1,170 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// embeddingChunkSize bounds the runes of crawled content embedded per chunk
+const embeddingChunkSize = 1000
+
+// maxEmbeddingChunks bounds the chunks embedded per result
+const maxEmbeddingChunks = 4
+
+// RerankEmbedding ranks results by cosine similarity between the query and each
+// result's title, snippet and crawled content chunks, dropping results whose
+// similarity is below EMBEDDING_THRESHOLD
+func RerankEmbedding(results []SearchResult, query string, opts SearchOptions) ([]SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	// The query comes first, followed by every chunk of every result
+	inputs := []string{query}
+	owners := []int{-1}
+	for i, result := range results {
+		for _, chunk := range embeddingChunks(result) {
+			inputs = append(inputs, chunk)
+			owners = append(owners, i)
+		}
+	}
+
+	vectors, err := embed(inputs, opts.APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// A result scores as its best matching chunk
+	scores := make([]float64, len(results))
+	for i := range scores {
+		scores[i] = -1
+	}
+	for i := 1; i < len(vectors); i++ {
+		if sim := cosine(vectors[0], vectors[i]); sim > scores[owners[i]] {
+			scores[owners[i]] = sim
+		}
+	}
+
+	threshold, _ := strconv.ParseFloat(os.Getenv("EMBEDDING_THRESHOLD"), 64)
+	var ranked []SearchResult
+	for i, result := range results {
+		if scores[i] < threshold {
+			continue
+		}
+		result.Score = math.Round(scores[i]*1000) / 1000
+		ranked = append(ranked, result)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked, nil
+}
+
+// embeddingChunks splits a result into texts to embed
+func embeddingChunks(result SearchResult) []string {
+	chunks := []string{strings.TrimSpace(result.Title + "\n" + result.Snippet)}
+	content := []rune(result.Content)
+	for start := 0; start < len(content) && len(chunks) <= maxEmbeddingChunks; start += embeddingChunkSize {
+		end := start + embeddingChunkSize
+		if end > len(content) {
+			end = len(content)
+		}
+		chunks = append(chunks, string(content[start:end]))
+	}
+	return chunks
+}
+
+// embed calls an OpenAI-compatible embeddings endpoint
+func embed(inputs []string, apiKey string) ([][]float64, error) {
+	upstream := os.Getenv("APIBASE")
+	if upstream == "" {
+		upstream = "https://api.openai.com"
+	}
+	baseURL := os.Getenv("EMBEDDING_BASE_URL")
+	if baseURL == "" {
+		baseURL = upstream
+	}
+	if key := os.Getenv("EMBEDDING_API_KEY"); key != "" {
+		apiKey = key
+	} else if strings.TrimSuffix(baseURL, "/") != strings.TrimSuffix(upstream, "/") {
+		// The client's key belongs to the upstream API and must not leak to another service
+		apiKey = ""
+	}
+	model := os.Getenv("EMBEDDING_MODEL")
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(baseURL, "/")+"/v1/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding API returned status %d", resp.StatusCode)
+	}
+
+	var embeddingResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, err
+	}
+	if len(embeddingResp.Data) != len(inputs) {
+		return nil, fmt.Errorf("embedding API returned %d vectors for %d inputs", len(embeddingResp.Data), len(inputs))
+	}
+
+	vectors := make([][]float64, len(inputs))
+	for _, item := range embeddingResp.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding API returned invalid index %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// cosine returns the cosine similarity of two vectors
+func cosine(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,43 @@
+package units
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestEmbedAPIKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		upstream     bool
+		embeddingKey string
+		want         string
+	}{
+		{name: "upstream API receives the client key", upstream: true, want: "Bearer client-key"},
+		{name: "separate service does not receive the client key", want: ""},
+		{name: "separate service with its own key", embeddingKey: "embedding-key", want: "Bearer embedding-key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveJSONFixture(t, "/v1/embeddings", "embeddings.json", func(r *http.Request, body map[string]interface{}) {
+				if got := r.Header.Get("Authorization"); got != tt.want {
+					t.Errorf("Authorization = %q, want %q", got, tt.want)
+				}
+			})
+			t.Setenv("EMBEDDING_BASE_URL", srv)
+			t.Setenv("EMBEDDING_API_KEY", tt.embeddingKey)
+			t.Setenv("APIBASE", "https://api.openai.com")
+			if tt.upstream {
+				t.Setenv("APIBASE", srv+"/")
+			}
+
+			vectors, err := embed([]string{"query", "result"}, "client-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vectors) != 2 {
+				t.Errorf("got %d vectors, want 2", len(vectors))
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func searchWithExa(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("EXA_KEY")
+	searchType := os.Getenv("EXA_SEARCH_TYPE")
+	if searchType == "" {
+		searchType = "auto"
+	}
+	maxCharacters := parseInt(os.Getenv("EXA_TEXT_MAX_CHARS"))
+	if maxCharacters <= 0 {
+		maxCharacters = 3000
+	}
+
+	reqBody := map[string]interface{}{
+		"query":      query,
+		"numResults": CandidateCount(),
+		"type":       searchType,
+		"contents": map[string]interface{}{
+			"text":       map[string]interface{}{"maxCharacters": maxCharacters},
+			"highlights": map[string]interface{}{"numSentences": 3, "highlightsPerUrl": 2},
+		},
+	}
+	if len(opts.IncludeDomains) > 0 {
+		reqBody["includeDomains"] = opts.IncludeDomains
+	}
+	if len(opts.ExcludeDomains) > 0 {
+		reqBody["excludeDomains"] = opts.ExcludeDomains
+	}
+	start, end := opts.TimeRange.Bounds(time.Now())
+	if !start.IsZero() {
+		reqBody["startPublishedDate"] = start.UTC().Format(time.RFC3339)
+	}
+	if !end.IsZero() {
+		reqBody["endPublishedDate"] = end.UTC().Format(time.RFC3339)
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", envBaseURL("EXA_BASE_URL", "https://api.exa.ai")+"/search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var exaResp struct {
+		Results []struct {
+			Title         string   `json:"title"`
+			URL           string   `json:"url"`
+			PublishedDate string   `json:"publishedDate"`
+			Author        string   `json:"author"`
+			Score         float64  `json:"score"`
+			Text          string   `json:"text"`
+			Highlights    []string `json:"highlights"`
+			Favicon       string   `json:"favicon"`
+			Image         string   `json:"image"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&exaResp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, item := range exaResp.Results {
+		// Highlights are the passages most relevant to the query; fall back to the start of the text
+		snippet := strings.Join(item.Highlights, " … ")
+		if snippet == "" {
+			snippet = TruncateText(item.Text, 300)
+		}
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			Link:        item.URL,
+			Snippet:     snippet,
+			PublishedAt: item.PublishedDate,
+			Favicon:     item.Favicon,
+			Thumbnail:   item.Image,
+			Score:       item.Score,
+			Content:     item.Text,
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,67 @@
+package units
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchWithExa(t *testing.T) {
+	var body map[string]interface{}
+	baseURL := serveJSONFixture(t, "/search", "exa.json", func(r *http.Request, b map[string]interface{}) {
+		if got := r.Header.Get("x-api-key"); got != "exa-test" {
+			t.Errorf("x-api-key = %q", got)
+		}
+		body = b
+	})
+	t.Setenv("SEARCH_SERVICE", "exa")
+	t.Setenv("EXA_BASE_URL", baseURL)
+	t.Setenv("EXA_KEY", "exa-test")
+	t.Setenv("EXA_SEARCH_TYPE", "")
+	t.Setenv("EXA_TEXT_MAX_CHARS", "")
+	t.Setenv("MAX_RESULTS", "10")
+
+	results, err := SearchResults("go 1.23", SearchOptions{
+		TimeRange:      TimeRange{Period: PeriodCustom, Start: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+		ExcludeDomains: []string{"spam.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body["type"] != "auto" || body["numResults"] != float64(10) {
+		t.Errorf("type = %v, numResults = %v", body["type"], body["numResults"])
+	}
+	if start, _ := body["startPublishedDate"].(string); !strings.HasPrefix(start, "2024-0") {
+		t.Errorf("startPublishedDate = %v", body["startPublishedDate"])
+	}
+	if exclude, _ := body["excludeDomains"].([]interface{}); len(exclude) != 1 || exclude[0] != "spam.example" {
+		t.Errorf("excludeDomains = %v", body["excludeDomains"])
+	}
+	contents, _ := body["contents"].(map[string]interface{})
+	if _, ok := contents["highlights"]; !ok {
+		t.Errorf("contents = %v, want highlights requested", contents)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	first := results[0]
+	if first.Snippet != "Go 1.23 adds iterators. … Telemetry is opt-in." {
+		t.Errorf("Snippet = %q, want the joined highlights", first.Snippet)
+	}
+	if !strings.HasPrefix(first.Content, "Today the Go team") || first.Thumbnail != "https://go.dev/blog/go1.23/card.png" ||
+		first.Score != 0.42 || !strings.HasPrefix(first.PublishedAt, "2024-08-13") {
+		t.Errorf("first result = %+v", first)
+	}
+
+	// Without highlights the snippet is the start of the text
+	second := results[1]
+	if len([]rune(second.Snippet)) > 301 || !strings.HasPrefix(second.Snippet, "word word") {
+		t.Errorf("Snippet = %q, want the truncated text", second.Snippet)
+	}
+	if len(second.Content) <= len(second.Snippet) {
+		t.Errorf("Content = %q, want the full text", second.Content)
+	}
+}
@@ -0,0 +1,101 @@
+package units
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestSearchWithGooglePaging(t *testing.T) {
+	pages := map[string][]byte{
+		"1":  readFixture(t, "google.json"),
+		"11": readFixture(t, "google_last.json"),
+	}
+
+	tests := []struct {
+		name       string
+		rerank     string
+		candidates string
+		// requests lists the num and start of each page requested
+		requests [][2]string
+		count    int
+	}{
+		{
+			name:     "without reranking a single page of MAX_RESULTS",
+			requests: [][2]string{{"5", "1"}},
+			count:    5,
+		},
+		{
+			name:       "reranking pages through the candidate pool",
+			rerank:     "bm25",
+			candidates: "15",
+			requests:   [][2]string{{"10", "1"}, {"5", "11"}},
+			count:      12,
+		},
+		{
+			name:       "candidate pool within one page",
+			rerank:     "bm25",
+			candidates: "8",
+			requests:   [][2]string{{"8", "1"}},
+			count:      8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests [][2]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if query.Get("cx") != "test-cx" || query.Get("key") != "test-key" || query.Get("q") != "go iterators" {
+					t.Errorf("query = %v", query)
+				}
+				requests = append(requests, [2]string{query.Get("num"), query.Get("start")})
+				page, ok := pages[query.Get("start")]
+				if !ok {
+					http.Error(w, `{"error":{"code":400,"message":"Invalid Value"}}`, http.StatusBadRequest)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(page)
+			}))
+			t.Cleanup(srv.Close)
+			t.Setenv("GOOGLE_BASE_URL", srv.URL)
+			t.Setenv("GOOGLE_CX", "test-cx")
+			t.Setenv("GOOGLE_KEY", "test-key")
+			t.Setenv("MAX_RESULTS", "5")
+			t.Setenv("RERANK", tt.rerank)
+			t.Setenv("RERANK_CANDIDATES", tt.candidates)
+
+			results, err := searchWithGoogle("go iterators", SearchOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("requests = %v, want %v", requests, tt.requests)
+			}
+			if len(results) != tt.count {
+				t.Errorf("got %d results, want %d", len(results), tt.count)
+			}
+			first := results[0]
+			if first.Source != "The Go Programming Language" || first.PublishedAt != "2024-08-13T00:00:00Z" ||
+				first.Language != "en_US" || first.Thumbnail == "" {
+				t.Errorf("first result = %+v", first)
+			}
+		})
+	}
+}
+
+func TestSearchWithGoogleError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":429,"message":"Quota exceeded"}}`, http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("GOOGLE_BASE_URL", srv.URL)
+	t.Setenv("RERANK", "")
+
+	if _, err := searchWithGoogle("go iterators", SearchOptions{}); err == nil {
+		t.Error("got no error for a failed first page")
+	}
+}
@@ -0,0 +1,49 @@
+package units
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	clientOnce sync.Once
+	httpClient *http.Client
+)
+
+// NewTransport creates an HTTP transport with connect and response header timeouts.
+// A zero timeout disables the corresponding limit.
+func NewTransport(connectTimeout, headerTimeout time.Duration) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: headerTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// getHTTPClient returns the shared client used by the search and crawler backends
+func getHTTPClient() *http.Client {
+	clientOnce.Do(func() {
+		timeout := 30 * time.Second
+		if n, err := strconv.Atoi(os.Getenv("SEARCH_TIMEOUT")); err == nil && n > 0 {
+			timeout = time.Duration(n) * time.Second
+		}
+		httpClient = &http.Client{
+			Transport: NewTransport(10*time.Second, timeout),
+			Timeout:   timeout,
+		}
+	})
+	return httpClient
+}
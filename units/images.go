@@ -0,0 +1,290 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ImageResult represents a single image search result
+type ImageResult struct {
+	Title string `json:"title"`
+	// ImageURL is the full-size image, Thumbnail a smaller copy hosted by the provider
+	ImageURL  string `json:"image_url"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	// SourcePage is the page the image appears on
+	SourcePage string `json:"source_page,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Provider   string `json:"provider,omitempty"`
+}
+
+// ImageResults searches the configured service's image vertical
+func ImageResults(query string) ([]ImageResult, error) {
+	fmt.Printf("正在搜索图片: %s\n", query)
+
+	searchService := os.Getenv("SEARCH_SERVICE")
+	if searchService == "" {
+		searchService = "duckduckgo"
+	}
+
+	var results []ImageResult
+	var err error
+
+	switch searchService {
+	case "bing":
+		results, err = imagesWithBing(query)
+	case "serpapi":
+		results, err = imagesWithSerpAPI(query)
+	case "serper":
+		results, err = imagesWithSerper(query)
+	case "searxng":
+		results, err = imagesWithSearXNG(query)
+	default:
+		return nil, fmt.Errorf("搜索服务 %s 不支持图片搜索", searchService)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("图片搜索失败: %v", err)
+	}
+
+	blocked := blockedDomains()
+	var filtered []ImageResult
+	for _, result := range results {
+		if result.ImageURL == "" {
+			continue
+		}
+		if u, err := url.Parse(result.SourcePage); err == nil && matchesDomain(u.Hostname(), blocked) {
+			continue
+		}
+		result.Provider = searchService
+		filtered = append(filtered, result)
+	}
+
+	fmt.Println("图片搜索完成")
+	return filtered[:min(len(filtered), MaxResults())], nil
+}
+
+// SupportsImages reports whether the configured search service has an image search backend
+func SupportsImages() bool {
+	switch os.Getenv("SEARCH_SERVICE") {
+	case "bing", "serpapi", "serper", "searxng":
+		return true
+	}
+	return false
+}
+
+func imagesWithBing(query string) ([]ImageResult, error) {
+	apiKey := os.Getenv("BING_KEY")
+
+	req, err := http.NewRequest("GET", "https://api.bing.microsoft.com/v7.0/images/search?q="+url.QueryEscape(query), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var bingResp struct {
+		Value []struct {
+			Name               string `json:"name"`
+			ContentURL         string `json:"contentUrl"`
+			ThumbnailURL       string `json:"thumbnailUrl"`
+			Width              int    `json:"width"`
+			Height             int    `json:"height"`
+			HostPageURL        string `json:"hostPageUrl"`
+			HostPageDisplayURL string `json:"hostPageDisplayUrl"`
+		} `json:"value"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&bingResp); err != nil {
+		return nil, err
+	}
+
+	var results []ImageResult
+	for _, item := range bingResp.Value {
+		results = append(results, ImageResult{
+			Title:      item.Name,
+			ImageURL:   item.ContentURL,
+			Thumbnail:  item.ThumbnailURL,
+			Width:      item.Width,
+			Height:     item.Height,
+			SourcePage: item.HostPageURL,
+			Source:     item.HostPageDisplayURL,
+		})
+	}
+
+	return results, nil
+}
+
+func imagesWithSerpAPI(query string) ([]ImageResult, error) {
+	apiKey := os.Getenv("SERPAPI_KEY")
+
+	apiURL := fmt.Sprintf("https://serpapi.com/search?api_key=%s&engine=google_images&q=%s&google_domain=google.com",
+		url.QueryEscape(apiKey),
+		url.QueryEscape(query))
+
+	resp, err := getHTTPClient().Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var serpResp struct {
+		Images []struct {
+			Title          string `json:"title"`
+			Original       string `json:"original"`
+			Thumbnail      string `json:"thumbnail"`
+			OriginalWidth  int    `json:"original_width"`
+			OriginalHeight int    `json:"original_height"`
+			Link           string `json:"link"`
+			Source         string `json:"source"`
+		} `json:"images_results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&serpResp); err != nil {
+		return nil, err
+	}
+
+	var results []ImageResult
+	for _, item := range serpResp.Images {
+		results = append(results, ImageResult{
+			Title:      item.Title,
+			ImageURL:   item.Original,
+			Thumbnail:  item.Thumbnail,
+			Width:      item.OriginalWidth,
+			Height:     item.OriginalHeight,
+			SourcePage: item.Link,
+			Source:     item.Source,
+		})
+	}
+
+	return results, nil
+}
+
+func imagesWithSerper(query string) ([]ImageResult, error) {
+	apiKey := os.Getenv("SERPER_KEY")
+	gl := os.Getenv("GL")
+	if gl == "" {
+		gl = "us"
+	}
+	hl := os.Getenv("HL")
+	if hl == "" {
+		hl = "en"
+	}
+
+	reqBody := map[string]string{
+		"q":  query,
+		"gl": gl,
+		"hl": hl,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "https://google.serper.dev/images", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-API-KEY", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var serperResp struct {
+		Images []struct {
+			Title        string `json:"title"`
+			ImageURL     string `json:"imageUrl"`
+			ImageWidth   int    `json:"imageWidth"`
+			ImageHeight  int    `json:"imageHeight"`
+			ThumbnailURL string `json:"thumbnailUrl"`
+			Source       string `json:"source"`
+			Link         string `json:"link"`
+		} `json:"images"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&serperResp); err != nil {
+		return nil, err
+	}
+
+	var results []ImageResult
+	for _, item := range serperResp.Images {
+		results = append(results, ImageResult{
+			Title:      item.Title,
+			ImageURL:   item.ImageURL,
+			Thumbnail:  item.ThumbnailURL,
+			Width:      item.ImageWidth,
+			Height:     item.ImageHeight,
+			SourcePage: item.Link,
+			Source:     item.Source,
+		})
+	}
+
+	return results, nil
+}
+
+func imagesWithSearXNG(query string) ([]ImageResult, error) {
+	baseURL := os.Getenv("SEARXNG_BASE_URL")
+
+	apiURL := fmt.Sprintf("%s/search?q=%s&categories=images&format=json",
+		baseURL,
+		url.QueryEscape(query))
+
+	resp, err := getHTTPClient().Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var searxResp struct {
+		Results []struct {
+			Title        string `json:"title"`
+			URL          string `json:"url"`
+			ImgSrc       string `json:"img_src"`
+			ThumbnailSrc string `json:"thumbnail_src"`
+			Resolution   string `json:"resolution"`
+			Source       string `json:"source"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&searxResp); err != nil {
+		return nil, err
+	}
+
+	var results []ImageResult
+	for _, item := range searxResp.Results {
+		result := ImageResult{
+			Title:      item.Title,
+			ImageURL:   item.ImgSrc,
+			Thumbnail:  item.ThumbnailSrc,
+			SourcePage: item.URL,
+			Source:     item.Source,
+		}
+		// Resolution is reported as text such as "1920 x 1080"
+		resolution := strings.ReplaceAll(item.Resolution, "×", "x")
+		if parts := strings.Split(resolution, "x"); len(parts) == 2 {
+			result.Width = parseInt(strings.TrimSpace(parts[0]))
+			result.Height = parseInt(strings.TrimSpace(parts[1]))
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,302 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// NewsResults searches the configured service's news vertical and returns
+// articles sorted by publication date, newest first
+func NewsResults(query string, opts SearchOptions) ([]SearchResult, error) {
+	fmt.Printf("正在搜索新闻: %s\n", query)
+
+	searchService := os.Getenv("SEARCH_SERVICE")
+	if searchService == "" {
+		searchService = "duckduckgo"
+	}
+
+	include := normalizeDomains(opts.IncludeDomains)
+	exclude := normalizeDomains(append(blockedDomains(), opts.ExcludeDomains...))
+	providerQuery := withDomainOperators(query, include, exclude)
+	if nativeDomainFilter(searchService) {
+		providerQuery = query
+	}
+	opts.IncludeDomains, opts.ExcludeDomains = include, exclude
+
+	var results []SearchResult
+	var err error
+
+	switch searchService {
+	case "search1api":
+		results, err = querySearch1API("news", providerQuery)
+	case "google":
+		results, err = queryGoogleCSE(providerQuery, opts, "date")
+	case "bing":
+		results, err = newsWithBing(providerQuery, opts)
+	case "serpapi":
+		results, err = newsWithSerpAPI(providerQuery, opts)
+	case "serper":
+		results, err = newsWithSerper(providerQuery, opts)
+	case "searxng":
+		results, err = querySearXNG(providerQuery, opts, "news")
+	// The services below have no news vertical wired up, so fall back to web
+	// search; results are still sorted by date below
+	case "duckduckgo":
+		results, err = searchWithDuckDuckGo(providerQuery, opts)
+	case "brave":
+		results, err = searchWithBrave(providerQuery, opts)
+	case "tavily":
+		results, _, err = searchWithTavily(providerQuery, opts)
+	case "exa":
+		results, err = searchWithExa(providerQuery, opts)
+	case "bocha":
+		results, err = searchWithBocha(providerQuery, opts)
+	case "qianfan":
+		results, err = searchWithQianfan(providerQuery, opts)
+	case "zhipu":
+		results, err = searchWithZhipu(providerQuery, opts)
+	default:
+		return nil, fmt.Errorf("不支持的搜索服务: %s", searchService)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("新闻搜索失败: %v", err)
+	}
+
+	enrichResults(results, searchService)
+	results = filterByDomain(results, include, exclude)
+	if !nativeNewsTimeRange(searchService, opts.TimeRange) {
+		results = filterByTimeRange(results, opts.TimeRange)
+	}
+	sortByRecency(results)
+	results = results[:min(len(results), MaxResults())]
+
+	fmt.Println("新闻搜索完成")
+	return results, nil
+}
+
+// nativeNewsTimeRange reports whether a service's news endpoint applies the time range itself
+func nativeNewsTimeRange(service string, tr TimeRange) bool {
+	switch service {
+	case "bing":
+		// Bing News freshness only supports day, week and month
+		return tr.Period != PeriodYear && tr.Period != PeriodCustom
+	case "google":
+		// The date sort replaces the custom range restriction
+		return tr.Period != PeriodCustom
+	}
+	return nativeTimeRange(service, tr)
+}
+
+// sortByRecency orders results newest first, keeping undated results last in their original order
+func sortByRecency(results []SearchResult) {
+	times := make(map[string]time.Time, len(results))
+	for _, result := range results {
+		if t, err := time.Parse(time.RFC3339, result.PublishedAt); err == nil {
+			times[result.Link] = t
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		ti, okI := times[results[i].Link]
+		tj, okJ := times[results[j].Link]
+		if okI != okJ {
+			return okI
+		}
+		return ti.After(tj)
+	})
+}
+
+func newsWithBing(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("BING_KEY")
+
+	apiURL := "https://api.bing.microsoft.com/v7.0/news/search?sortBy=Date&q=" + url.QueryEscape(query)
+	if nativeNewsTimeRange("bing", opts.TimeRange) {
+		if freshness := bingFreshness(opts.TimeRange); freshness != "" {
+			apiURL += "&freshness=" + freshness
+		}
+	}
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var bingResp struct {
+		Value []struct {
+			Name          string `json:"name"`
+			URL           string `json:"url"`
+			Description   string `json:"description"`
+			DatePublished string `json:"datePublished"`
+			Provider      []struct {
+				Name string `json:"name"`
+			} `json:"provider"`
+			Image struct {
+				Thumbnail struct {
+					ContentURL string `json:"contentUrl"`
+				} `json:"thumbnail"`
+			} `json:"image"`
+		} `json:"value"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&bingResp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, item := range bingResp.Value {
+		result := SearchResult{
+			Title:       item.Name,
+			Link:        item.URL,
+			Snippet:     item.Description,
+			PublishedAt: item.DatePublished,
+			Thumbnail:   item.Image.Thumbnail.ContentURL,
+		}
+		if len(item.Provider) > 0 {
+			result.Source = item.Provider[0].Name
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func newsWithSerpAPI(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("SERPAPI_KEY")
+
+	apiURL := fmt.Sprintf("https://serpapi.com/search?api_key=%s&engine=google&tbm=nws&q=%s&google_domain=google.com",
+		url.QueryEscape(apiKey),
+		url.QueryEscape(query))
+	if tbs := googleTBS(opts.TimeRange); tbs != "" {
+		apiURL += "&tbs=" + url.QueryEscape(tbs)
+	}
+
+	resp, err := getHTTPClient().Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var serpResp struct {
+		News []struct {
+			Position  int         `json:"position"`
+			Title     string      `json:"title"`
+			Link      string      `json:"link"`
+			Snippet   string      `json:"snippet"`
+			Source    interface{} `json:"source"`
+			Date      string      `json:"date"`
+			Thumbnail string      `json:"thumbnail"`
+		} `json:"news_results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&serpResp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, item := range serpResp.News {
+		result := SearchResult{
+			Title:       item.Title,
+			Link:        item.Link,
+			Snippet:     item.Snippet,
+			PublishedAt: item.Date,
+			Thumbnail:   item.Thumbnail,
+			Position:    item.Position,
+		}
+		// The source is a name in Google News tab results and an object in some layouts
+		switch source := item.Source.(type) {
+		case string:
+			result.Source = source
+		case map[string]interface{}:
+			result.Source = firstString(source, "name")
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func newsWithSerper(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("SERPER_KEY")
+	gl := os.Getenv("GL")
+	if gl == "" {
+		gl = "us"
+	}
+	hl := os.Getenv("HL")
+	if hl == "" {
+		hl = "en"
+	}
+
+	reqBody := map[string]string{
+		"q":  query,
+		"gl": gl,
+		"hl": hl,
+	}
+	if tbs := googleTBS(opts.TimeRange); tbs != "" {
+		reqBody["tbs"] = tbs
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "https://google.serper.dev/news", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-API-KEY", apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var serperResp struct {
+		News []struct {
+			Title    string `json:"title"`
+			Link     string `json:"link"`
+			Snippet  string `json:"snippet"`
+			Date     string `json:"date"`
+			Source   string `json:"source"`
+			ImageURL string `json:"imageUrl"`
+			Position int    `json:"position"`
+		} `json:"news"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&serperResp); err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	for _, item := range serperResp.News {
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			Link:        item.Link,
+			Snippet:     item.Snippet,
+			PublishedAt: item.Date,
+			Source:      item.Source,
+			Thumbnail:   item.ImageURL,
+			Position:    item.Position,
+			Language:    hl,
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,401 @@
+package units
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Paper represents a single academic paper
+type Paper struct {
+	Title    string   `json:"title"`
+	Authors  []string `json:"authors,omitempty"`
+	Year     int      `json:"year,omitempty"`
+	Venue    string   `json:"venue,omitempty"`
+	Abstract string   `json:"abstract,omitempty"`
+	DOI      string   `json:"doi,omitempty"`
+	// URL is the landing page, PDFURL a full-text PDF when one is openly available
+	URL    string `json:"url,omitempty"`
+	PDFURL string `json:"pdf_url,omitempty"`
+	// Provider is the index the paper was found in: arxiv, semanticscholar or crossref
+	Provider string `json:"provider,omitempty"`
+}
+
+// paperSearchers maps PAPER_SOURCES names to their search functions
+var paperSearchers = map[string]func(query string, limit int) ([]Paper, error){
+	"arxiv":           papersWithArxiv,
+	"semanticscholar": papersWithSemanticScholar,
+	"crossref":        papersWithCrossref,
+}
+
+// PaperResults searches the configured academic indexes in parallel and merges their results
+func PaperResults(query string) ([]Paper, error) {
+	fmt.Printf("正在搜索论文: %s\n", query)
+
+	sources := strings.Split(os.Getenv("PAPER_SOURCES"), ",")
+	if os.Getenv("PAPER_SOURCES") == "" {
+		sources = []string{"arxiv", "semanticscholar", "crossref"}
+	}
+
+	limit := MaxResults()
+	sets := make([][]Paper, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		source = strings.TrimSpace(source)
+		search, ok := paperSearchers[source]
+		if !ok {
+			errs[i] = fmt.Errorf("不支持的论文来源: %s", source)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, source string) {
+			defer wg.Done()
+			papers, err := search(query, limit)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %v", source, err)
+				return
+			}
+			for j := range papers {
+				papers[j].Provider = source
+			}
+			sets[i] = papers
+		}(i, source)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			log.Printf("论文搜索失败: %v", err)
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) == len(sources) {
+		return nil, fmt.Errorf("论文搜索失败: %s", strings.Join(failed, "; "))
+	}
+
+	papers := mergePapers(sets)
+	fmt.Println("论文搜索完成")
+	return papers[:min(len(papers), limit)], nil
+}
+
+// mergePapers interleaves the result sets by rank and merges duplicates,
+// matched by DOI or normalised title, filling in fields missing from the first copy
+func mergePapers(sets [][]Paper) []Paper {
+	var merged []Paper
+	index := make(map[string]int)
+	for rank := 0; ; rank++ {
+		added := false
+		for _, set := range sets {
+			if rank >= len(set) {
+				continue
+			}
+			added = true
+			paper := set[rank]
+			if paper.Title == "" {
+				continue
+			}
+
+			keys := []string{"title:" + paperTitleKey(paper.Title)}
+			if paper.DOI != "" {
+				keys = append(keys, "doi:"+strings.ToLower(paper.DOI))
+			}
+			existing := -1
+			for _, key := range keys {
+				if i, ok := index[key]; ok {
+					existing = i
+					break
+				}
+			}
+			if existing < 0 {
+				merged = append(merged, paper)
+				existing = len(merged) - 1
+			} else {
+				fillPaper(&merged[existing], paper)
+			}
+			for _, key := range keys {
+				index[key] = existing
+			}
+		}
+		if !added {
+			return merged
+		}
+	}
+}
+
+// fillPaper copies fields from other that are empty in p
+func fillPaper(p *Paper, other Paper) {
+	if len(p.Authors) == 0 {
+		p.Authors = other.Authors
+	}
+	if p.Year == 0 {
+		p.Year = other.Year
+	}
+	if p.Venue == "" {
+		p.Venue = other.Venue
+	}
+	if p.Abstract == "" {
+		p.Abstract = other.Abstract
+	}
+	if p.DOI == "" {
+		p.DOI = other.DOI
+	}
+	if p.URL == "" {
+		p.URL = other.URL
+	}
+	if p.PDFURL == "" {
+		p.PDFURL = other.PDFURL
+	}
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// paperTitleKey normalises a title for duplicate detection
+func paperTitleKey(title string) string {
+	return nonAlphanumeric.ReplaceAllString(strings.ToLower(title), "")
+}
+
+func papersWithArxiv(query string, limit int) ([]Paper, error) {
+	apiURL := fmt.Sprintf("%s/query?search_query=%s&start=0&max_results=%d&sortBy=relevance",
+		envBaseURL("ARXIV_BASE_URL", "http://export.arxiv.org/api"),
+		url.QueryEscape("all:"+query),
+		limit)
+
+	resp, err := getHTTPClient().Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var feed struct {
+		Entries []struct {
+			ID        string `xml:"id"`
+			Title     string `xml:"title"`
+			Summary   string `xml:"summary"`
+			Published string `xml:"published"`
+			Authors   []struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+			Links []struct {
+				Href  string `xml:"href,attr"`
+				Title string `xml:"title,attr"`
+				Type  string `xml:"type,attr"`
+			} `xml:"link"`
+			DOI        string `xml:"http://arxiv.org/schemas/atom doi"`
+			JournalRef string `xml:"http://arxiv.org/schemas/atom journal_ref"`
+		} `xml:"entry"`
+	}
+
+	if err := xml.NewDecoder(resp.Body).Decode(&feed); err != nil {
+		return nil, err
+	}
+
+	var papers []Paper
+	for _, entry := range feed.Entries {
+		paper := Paper{
+			Title:    collapseSpace(entry.Title),
+			Abstract: collapseSpace(entry.Summary),
+			DOI:      entry.DOI,
+			URL:      entry.ID,
+			Venue:    collapseSpace(entry.JournalRef),
+		}
+		if paper.Venue == "" {
+			paper.Venue = "arXiv"
+		}
+		if len(entry.Published) >= 4 {
+			paper.Year = parseInt(entry.Published[:4])
+		}
+		for _, author := range entry.Authors {
+			paper.Authors = append(paper.Authors, author.Name)
+		}
+		for _, link := range entry.Links {
+			if link.Title == "pdf" || link.Type == "application/pdf" {
+				paper.PDFURL = link.Href
+			}
+		}
+		papers = append(papers, paper)
+	}
+
+	return papers, nil
+}
+
+func papersWithSemanticScholar(query string, limit int) ([]Paper, error) {
+	apiURL := fmt.Sprintf("%s/paper/search?query=%s&limit=%d&fields=%s",
+		envBaseURL("SEMANTIC_SCHOLAR_BASE_URL", "https://api.semanticscholar.org/graph/v1"),
+		url.QueryEscape(query),
+		limit,
+		"title,authors,year,venue,abstract,externalIds,openAccessPdf,url")
+
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey := os.Getenv("SEMANTIC_SCHOLAR_KEY"); apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var s2Resp struct {
+		Data []struct {
+			Title    string `json:"title"`
+			Year     int    `json:"year"`
+			Venue    string `json:"venue"`
+			Abstract string `json:"abstract"`
+			URL      string `json:"url"`
+			Authors  []struct {
+				Name string `json:"name"`
+			} `json:"authors"`
+			ExternalIDs struct {
+				DOI   string `json:"DOI"`
+				ArXiv string `json:"ArXiv"`
+			} `json:"externalIds"`
+			OpenAccessPdf *struct {
+				URL string `json:"url"`
+			} `json:"openAccessPdf"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&s2Resp); err != nil {
+		return nil, err
+	}
+
+	var papers []Paper
+	for _, item := range s2Resp.Data {
+		paper := Paper{
+			Title:    item.Title,
+			Year:     item.Year,
+			Venue:    item.Venue,
+			Abstract: item.Abstract,
+			DOI:      item.ExternalIDs.DOI,
+			URL:      item.URL,
+		}
+		for _, author := range item.Authors {
+			paper.Authors = append(paper.Authors, author.Name)
+		}
+		if item.OpenAccessPdf != nil && item.OpenAccessPdf.URL != "" {
+			paper.PDFURL = item.OpenAccessPdf.URL
+		} else if item.ExternalIDs.ArXiv != "" {
+			paper.PDFURL = "https://arxiv.org/pdf/" + item.ExternalIDs.ArXiv
+		}
+		papers = append(papers, paper)
+	}
+
+	return papers, nil
+}
+
+func papersWithCrossref(query string, limit int) ([]Paper, error) {
+	apiURL := fmt.Sprintf("%s/works?query=%s&rows=%d&select=%s",
+		envBaseURL("CROSSREF_BASE_URL", "https://api.crossref.org"),
+		url.QueryEscape(query),
+		limit,
+		"DOI,title,author,issued,container-title,abstract,URL,link")
+	// Identified requests are served from Crossref's more reliable polite pool
+	if mailto := os.Getenv("CROSSREF_MAILTO"); mailto != "" {
+		apiURL += "&mailto=" + url.QueryEscape(mailto)
+	}
+
+	resp, err := getHTTPClient().Get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var crossrefResp struct {
+		Message struct {
+			Items []struct {
+				DOI      string   `json:"DOI"`
+				Title    []string `json:"title"`
+				URL      string   `json:"URL"`
+				Abstract string   `json:"abstract"`
+				Author   []struct {
+					Given  string `json:"given"`
+					Family string `json:"family"`
+					Name   string `json:"name"`
+				} `json:"author"`
+				Issued struct {
+					DateParts [][]int `json:"date-parts"`
+				} `json:"issued"`
+				ContainerTitle []string `json:"container-title"`
+				Link           []struct {
+					URL         string `json:"URL"`
+					ContentType string `json:"content-type"`
+				} `json:"link"`
+			} `json:"items"`
+		} `json:"message"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&crossrefResp); err != nil {
+		return nil, err
+	}
+
+	var papers []Paper
+	for _, item := range crossrefResp.Message.Items {
+		if len(item.Title) == 0 {
+			continue
+		}
+		paper := Paper{
+			Title:    collapseSpace(item.Title[0]),
+			DOI:      item.DOI,
+			URL:      item.URL,
+			Abstract: crossrefAbstract(item.Abstract),
+		}
+		for _, author := range item.Author {
+			name := strings.TrimSpace(author.Given + " " + author.Family)
+			if name == "" {
+				name = author.Name
+			}
+			paper.Authors = append(paper.Authors, name)
+		}
+		if len(item.Issued.DateParts) > 0 && len(item.Issued.DateParts[0]) > 0 {
+			paper.Year = item.Issued.DateParts[0][0]
+		}
+		if len(item.ContainerTitle) > 0 {
+			paper.Venue = item.ContainerTitle[0]
+		}
+		for _, link := range item.Link {
+			if link.ContentType == "application/pdf" {
+				paper.PDFURL = link.URL
+				break
+			}
+		}
+		papers = append(papers, paper)
+	}
+
+	return papers, nil
+}
+
+// jatsTitle matches the "Abstract" heading many publishers put before the text
+var jatsTitle = regexp.MustCompile(`(?s)<jats:title>.*?</jats:title>`)
+
+// crossrefAbstract converts a JATS abstract to plain text
+func crossrefAbstract(abstract string) string {
+	abstract = jatsTitle.ReplaceAllString(abstract, "")
+	abstract = strings.ReplaceAll(abstract, "</jats:p>", " ")
+	return collapseSpace(htmlTag.ReplaceAllString(abstract, ""))
+}
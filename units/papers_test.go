@@ -0,0 +1,182 @@
+package units
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// servePaperFixtures serves the recorded arXiv, Semantic Scholar and Crossref
+// responses under /arxiv, /s2 and /crossref, and points the base URLs at them
+func servePaperFixtures(t *testing.T, failing ...string) {
+	t.Helper()
+	routes := map[string]string{
+		"/arxiv/query":     "arxiv.xml",
+		"/s2/paper/search": "semanticscholar.json",
+		"/crossref/works":  "crossref.json",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range failing {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		fixture, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Encode() == "" {
+			t.Errorf("%s: request has no query parameters", r.URL.Path)
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			t.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("ARXIV_BASE_URL", srv.URL+"/arxiv")
+	t.Setenv("SEMANTIC_SCHOLAR_BASE_URL", srv.URL+"/s2")
+	t.Setenv("CROSSREF_BASE_URL", srv.URL+"/crossref")
+	t.Setenv("PAPER_SOURCES", "")
+	t.Setenv("MAX_RESULTS", "10")
+}
+
+func TestPaperResultsMergesSources(t *testing.T) {
+	servePaperFixtures(t)
+
+	papers, err := PaperResults("attention")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Paper{
+		{
+			Title:    "Attention Is All You Need",
+			Authors:  []string{"Ashish Vaswani", "Noam Shazeer"},
+			Year:     2017,
+			Venue:    "arXiv",
+			Abstract: "The dominant sequence transduction models are based on complex recurrent or convolutional neural networks.",
+			DOI:      "10.48550/arXiv.1706.03762",
+			URL:      "http://arxiv.org/abs/1706.03762v7",
+			PDFURL:   "http://arxiv.org/pdf/1706.03762v7",
+			Provider: "arxiv",
+		},
+		{
+			// Found by Crossref, with the abstract and PDF filled in from Semantic Scholar by DOI
+			Title:    "Deep Residual Learning for Image Recognition",
+			Authors:  []string{"Kaiming He", "Xiangyu Zhang"},
+			Year:     2016,
+			Venue:    "2016 IEEE Conference on Computer Vision and Pattern Recognition (CVPR)",
+			Abstract: "Deeper neural networks are more difficult to train.",
+			DOI:      "10.1109/CVPR.2016.90",
+			URL:      "https://doi.org/10.1109/cvpr.2016.90",
+			PDFURL:   "https://example.org/resnet.pdf",
+			Provider: "crossref",
+		},
+		{
+			// Found by arXiv, with the DOI filled in from Crossref by title
+			Title:    "BERT: Pre-training of Deep Bidirectional Transformers for Language Understanding",
+			Authors:  []string{"Jacob Devlin"},
+			Year:     2018,
+			Venue:    "NAACL 2019",
+			Abstract: "We introduce a new language representation model called BERT.",
+			DOI:      "10.18653/v1/N19-1423",
+			URL:      "http://arxiv.org/abs/1810.04805v2",
+			PDFURL:   "http://arxiv.org/pdf/1810.04805v2",
+			Provider: "arxiv",
+		},
+	}
+	if !reflect.DeepEqual(papers, want) {
+		t.Errorf("PaperResults() =\n%+v\nwant\n%+v", papers, want)
+	}
+}
+
+func TestPaperResultsSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources string
+		failing []string
+		titles  []string
+		wantErr bool
+	}{
+		{
+			name:    "crossref only",
+			sources: "crossref",
+			titles: []string{
+				"Deep Residual Learning for Image Recognition",
+				"BERT: Pre-training of Deep Bidirectional Transformers for Language Understanding",
+			},
+		},
+		{
+			name:    "failed source is skipped",
+			sources: "semanticscholar, crossref",
+			failing: []string{"/s2"},
+			titles: []string{
+				"Deep Residual Learning for Image Recognition",
+				"BERT: Pre-training of Deep Bidirectional Transformers for Language Understanding",
+			},
+		},
+		{
+			name:    "all sources failed",
+			sources: "arxiv",
+			failing: []string{"/arxiv"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			sources: "scholar",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servePaperFixtures(t, tt.failing...)
+			t.Setenv("PAPER_SOURCES", tt.sources)
+
+			papers, err := PaperResults("query")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PaperResults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var titles []string
+			for _, paper := range papers {
+				titles = append(titles, paper.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("titles = %q, want %q", titles, tt.titles)
+			}
+		})
+	}
+}
+
+func TestCrossrefAbstract(t *testing.T) {
+	servePaperFixtures(t)
+
+	papers, err := papersWithCrossref("bert", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(papers) != 2 {
+		t.Fatalf("got %d papers, want 2 (untitled items are skipped)", len(papers))
+	}
+	bert := papers[1]
+	if bert.Abstract != "We introduce BERT. It is simple." {
+		t.Errorf("Abstract = %q", bert.Abstract)
+	}
+	if bert.PDFURL != "https://aclanthology.org/N19-1423.pdf" {
+		t.Errorf("PDFURL = %q", bert.PDFURL)
+	}
+	if !reflect.DeepEqual(bert.Authors, []string{"Google AI Language"}) {
+		t.Errorf("Authors = %q", bert.Authors)
+	}
+}
@@ -0,0 +1,454 @@
+package units
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Default limits for PDFs fetched by the crawler
+const (
+	defaultPDFMaxBytes = 20 << 20
+	defaultPDFMaxChars = 30000
+)
+
+// pdfMaxInflatedBytes bounds the decompressed size of all content streams,
+// which PDF_MAX_BYTES cannot, so that a small compression bomb cannot exhaust memory
+var pdfMaxInflatedBytes = 100 << 20
+
+// isPDFURL reports whether a URL points at a PDF, judging by its path
+func isPDFURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	return strings.HasSuffix(path, ".pdf") || (strings.HasSuffix(u.Hostname(), "arxiv.org") && strings.HasPrefix(path, "/pdf/"))
+}
+
+// crawlPDF downloads a PDF and extracts its text
+func crawlPDF(pdfURL string) (*CrawlResult, error) {
+	maxBytes := parseInt(os.Getenv("PDF_MAX_BYTES"))
+	if maxBytes <= 0 {
+		maxBytes = defaultPDFMaxBytes
+	}
+	maxChars := parseInt(os.Getenv("PDF_MAX_CHARS"))
+	if maxChars <= 0 {
+		maxChars = defaultPDFMaxChars
+	}
+
+	resp, err := getHTTPClient().Get(pdfURL)
+	if err != nil {
+		return nil, fmt.Errorf("PDF下载失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("PDF下载失败, 状态码: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("PDF下载失败: %v", err)
+	}
+	if len(data) > maxBytes {
+		return nil, fmt.Errorf("PDF超过大小限制 %d 字节", maxBytes)
+	}
+
+	text, err := extractPDFText(data)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := []rune(text)
+	truncated := len(runes) > maxChars
+	if truncated {
+		text = string(runes[:maxChars])
+	}
+
+	// The request of the response is the last one made, after any redirects
+	finalURL := pdfURL
+	if resp.Request != nil {
+		finalURL = resp.Request.URL.String()
+	}
+
+	return &CrawlResult{
+		URL:         pdfURL,
+		FinalURL:    finalURL,
+		Status:      resp.StatusCode,
+		Title:       pdfTitle(data),
+		Content:     text,
+		ContentType: "application/pdf",
+		Truncated:   truncated,
+	}, nil
+}
+
+var (
+	pdfStreamStart = regexp.MustCompile(`stream\r?\n`)
+	pdfInfoTitle   = regexp.MustCompile(`/Title\s*\(((?:\\.|[^\\)])*)\)`)
+)
+
+// pdfTitle returns the document title from the info dictionary, if it is stored uncompressed
+func pdfTitle(data []byte) string {
+	match := pdfInfoTitle.FindSubmatch(data)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(decodePDFString(unescapePDFLiteral(match[1])))
+}
+
+// extractPDFText pulls the text drawn by the content streams of a PDF.
+// It handles the Flate-compressed streams and simple font encodings used by
+// most papers; text in other encodings is reported as an error so callers can
+// fall back to a remote crawler.
+func extractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", fmt.Errorf("不是有效的PDF文件")
+	}
+
+	var sb strings.Builder
+	inflateBudget := int64(pdfMaxInflatedBytes)
+	for _, loc := range pdfStreamStart.FindAllIndex(data, -1) {
+		// Skip the "stream" inside "endstream"
+		if loc[0] >= 3 && string(data[loc[0]-3:loc[0]]) == "end" {
+			continue
+		}
+		dictStart := bytes.LastIndex(data[:loc[0]], []byte(" obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:loc[0]]
+		end := bytes.Index(data[loc[1]:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[loc[1] : loc[1]+end]
+
+		if !isContentStream(dict) {
+			continue
+		}
+		content := raw
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			content, err = io.ReadAll(io.LimitReader(r, inflateBudget+1))
+			r.Close()
+			if err != nil {
+				return "", fmt.Errorf("PDF数据流解压失败: %v", err)
+			}
+			inflateBudget -= int64(len(content))
+			if inflateBudget < 0 {
+				return "", fmt.Errorf("PDF解压后超过大小限制 %d 字节", pdfMaxInflatedBytes)
+			}
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		if !bytes.Contains(content, []byte("BT")) {
+			continue
+		}
+		sb.WriteString(pdfContentText(content))
+		sb.WriteString("\n")
+	}
+
+	text := cleanPDFText(sb.String())
+	if !mostlyReadable(text) {
+		return "", fmt.Errorf("无法从PDF中提取文本")
+	}
+	return text, nil
+}
+
+// isContentStream reports whether a stream dictionary may describe page content
+// rather than fonts, images, metadata or cross-reference data
+func isContentStream(dict []byte) bool {
+	for _, key := range []string{"/Subtype/Image", "/Subtype /Image", "/Length1", "/Length2", "/Type/XRef", "/Type /XRef", "/Type/ObjStm", "/Type /ObjStm", "/Type/Metadata", "/Type /Metadata", "/Subtype/Type1C", "/Subtype /Type1C", "/Subtype/CIDFontType0C", "/Subtype /CIDFontType0C", "/Subtype/OpenType", "/Subtype /OpenType"} {
+		if bytes.Contains(dict, []byte(key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// pdfContentText interprets the text operators of a content stream
+func pdfContentText(content []byte) string {
+	var sb strings.Builder
+	var operands []interface{}
+	var array []interface{}
+	inArray := false
+	inText := false
+
+	push := func(value interface{}) {
+		if inArray {
+			array = append(array, value)
+		} else {
+			operands = append(operands, value)
+		}
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			str, next := readPDFLiteral(content, i)
+			push(str)
+			i = next
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+		case c == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return sb.String()
+			}
+			push(decodePDFHex(content[i+1 : i+end]))
+			i += end + 1
+		case c == '[':
+			inArray = true
+			array = nil
+			i++
+		case c == ']':
+			inArray = false
+			operands = append(operands, array)
+			i++
+		case c == '/':
+			j := i + 1
+			for j < len(content) && !isPDFDelimiter(content[j]) {
+				j++
+			}
+			push(string(content[i:j]))
+			i = j
+		case isPDFDelimiter(c):
+			i++
+		default:
+			j := i
+			for j < len(content) && !isPDFDelimiter(content[j]) {
+				j++
+			}
+			token := string(content[i:j])
+			i = j
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				push(n)
+				continue
+			}
+			if inArray {
+				continue
+			}
+
+			switch token {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				sb.WriteString(" ")
+			case "Tj", "'", "\"":
+				if token != "Tj" {
+					sb.WriteString("\n")
+				}
+				if len(operands) > 0 {
+					if str, ok := operands[len(operands)-1].(string); ok && inText {
+						sb.WriteString(str)
+					}
+				}
+			case "TJ":
+				if len(operands) > 0 {
+					if items, ok := operands[len(operands)-1].([]interface{}); ok && inText {
+						for _, item := range items {
+							switch v := item.(type) {
+							case string:
+								sb.WriteString(v)
+							case float64:
+								// Large negative adjustments are word gaps
+								if v < -200 {
+									sb.WriteString(" ")
+								}
+							}
+						}
+					}
+				}
+			case "Td", "TD":
+				if len(operands) >= 2 {
+					if ty, ok := operands[len(operands)-1].(float64); ok && ty != 0 {
+						sb.WriteString("\n")
+					} else {
+						sb.WriteString(" ")
+					}
+				}
+			case "T*", "Tm":
+				sb.WriteString("\n")
+			}
+			operands = operands[:0]
+		}
+	}
+	return sb.String()
+}
+
+// isPDFDelimiter reports whether c ends a PDF token
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// readPDFLiteral reads a parenthesised string starting at content[start],
+// returning the decoded text and the index after the closing parenthesis
+func readPDFLiteral(content []byte, start int) (string, int) {
+	depth := 0
+	i := start
+	for ; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodePDFString(unescapePDFLiteral(content[start+1 : i])), i + 1
+			}
+		}
+	}
+	return decodePDFString(unescapePDFLiteral(content[start+1:])), i
+}
+
+// unescapePDFLiteral resolves the backslash escapes of a literal string
+func unescapePDFLiteral(s []byte) []byte {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r', '\n':
+			// Line continuation
+			if c == '\r' && i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		default:
+			if c >= '0' && c <= '7' {
+				n := 0
+				j := i
+				for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+					n = n*8 + int(s[j]-'0')
+				}
+				out = append(out, byte(n))
+				i = j - 1
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// decodePDFHex decodes a hex string body, padding an odd final digit as the spec requires
+func decodePDFHex(s []byte) string {
+	digits := bytes.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	decoded, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return ""
+	}
+	return decodePDFString(decoded)
+}
+
+// texLigatures maps the ligature codes of TeX fonts to their letters
+var texLigatures = map[byte]string{
+	0x0B: "ff",
+	0x0C: "fi",
+	0x0D: "fl",
+	0x0E: "ffi",
+	0x0F: "ffl",
+}
+
+// decodePDFString converts string bytes to text, handling UTF-16BE strings with a
+// byte order mark and treating other strings as a Latin-1 superset
+func decodePDFString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xFE && s[1] == 0xFF {
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+
+	var sb strings.Builder
+	for _, b := range s {
+		if ligature, ok := texLigatures[b]; ok {
+			sb.WriteString(ligature)
+		} else if b == '\n' || b == '\t' || b >= 0x20 {
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+// cleanPDFText collapses spaces within lines and runs of blank lines
+func cleanPDFText(text string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(text, "\n") {
+		line = collapseSpace(line)
+		if line == "" {
+			if !blank && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// mostlyReadable reports whether text looks like prose rather than glyph codes
+func mostlyReadable(text string) bool {
+	total, readable := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) {
+			readable++
+		}
+	}
+	return total >= 100 && readable*10 >= total*8
+}
@@ -0,0 +1,131 @@
+package units
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestExtractPDFText(t *testing.T) {
+	data := readFixture(t, "sample.pdf")
+
+	text, err := extractPDFText(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Attention Is All You Need",
+		"based solely on attention mechanisms.",
+		"superior in quality.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+	if title := pdfTitle(data); title != "Attention Is All You Need" {
+		t.Errorf("pdfTitle() = %q", title)
+	}
+}
+
+func TestExtractPDFTextErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a pdf", []byte("<html>not a pdf</html>")},
+		{"no text", []byte("%PDF-1.4\n1 0 obj\n<< /Length 4 >>\nstream\nq Q\nendstream\nendobj\n")},
+		{"corrupt stream", pdfWithStream([]byte("not zlib data at all, but long enough to read"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text, err := extractPDFText(tt.data); err == nil {
+				t.Errorf("extractPDFText() = %q, want an error", text)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextInflateLimit(t *testing.T) {
+	old := pdfMaxInflatedBytes
+	pdfMaxInflatedBytes = 1 << 20
+	defer func() { pdfMaxInflatedBytes = old }()
+
+	// A few KB of zlib data that inflates to four times the limit
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte("BT (x) Tj ET\n"))
+	w.Write(make([]byte, 4<<20))
+	w.Close()
+
+	_, err := extractPDFText(pdfWithStream(buf.Bytes()))
+	if err == nil || !strings.Contains(err.Error(), "大小限制") {
+		t.Errorf("extractPDFText() error = %v, want the inflate limit error", err)
+	}
+}
+
+// pdfWithStream wraps a Flate-compressed content stream in a minimal PDF object
+func pdfWithStream(stream []byte) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%%PDF-1.4\n4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(stream))
+	buf.Write(stream)
+	buf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return buf.Bytes()
+}
+
+func TestCrawlPDF(t *testing.T) {
+	data := readFixture(t, "sample.pdf")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect.pdf" {
+			http.Redirect(w, r, "/paper.pdf", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	t.Setenv("PDF_MAX_CHARS", "")
+	result, err := Crawl(srv.URL+"/redirect.pdf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.URL != srv.URL+"/redirect.pdf" || result.FinalURL != srv.URL+"/paper.pdf" {
+		t.Errorf("URL = %q, FinalURL = %q", result.URL, result.FinalURL)
+	}
+	if result.Title != "Attention Is All You Need" || result.ContentType != "application/pdf" || result.Truncated {
+		t.Errorf("result = %+v", result)
+	}
+	if result.FetchedAt == "" {
+		t.Error("FetchedAt is empty")
+	}
+
+	t.Setenv("PDF_MAX_CHARS", "40")
+	result, err = Crawl(srv.URL+"/paper.pdf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Truncated || len([]rune(result.Content)) != 40 {
+		t.Errorf("Truncated = %v, content length = %d", result.Truncated, len([]rune(result.Content)))
+	}
+
+	t.Setenv("PDF_MAX_BYTES", "100")
+	if _, err := crawlPDF(srv.URL + "/paper.pdf"); err == nil {
+		t.Error("crawlPDF() accepted a PDF over PDF_MAX_BYTES")
+	}
+}
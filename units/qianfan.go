@@ -0,0 +1,97 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+func searchWithQianfan(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("QIANFAN_KEY")
+
+	reqBody := map[string]interface{}{
+		"messages": []map[string]string{
+			{"role": "user", "content": query},
+		},
+		"search_source": "baidu_search_v2",
+		"resource_type_filter": []map[string]interface{}{
+			{"type": "web", "top_k": min(CandidateCount(), 50)},
+		},
+	}
+	if recency := qianfanRecency(opts.TimeRange); recency != "" {
+		reqBody["search_recency_filter"] = recency
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", envBaseURL("QIANFAN_BASE_URL", "https://qianfan.baidubce.com/v2")+"/ai_search/web_search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var qianfanResp struct {
+		Code       string `json:"code"`
+		Message    string `json:"message"`
+		References []struct {
+			Title   string `json:"title"`
+			URL     string `json:"url"`
+			Content string `json:"content"`
+			Date    string `json:"date"`
+			Icon    string `json:"icon"`
+			Website string `json:"website"`
+			Type    string `json:"type"`
+		} `json:"references"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&qianfanResp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK || qianfanResp.Code != "" {
+		return nil, fmt.Errorf("状态码: %d %s %s", resp.StatusCode, qianfanResp.Code, qianfanResp.Message)
+	}
+
+	var results []SearchResult
+	for _, item := range qianfanResp.References {
+		if item.Type != "" && item.Type != "web" {
+			continue
+		}
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			Link:        item.URL,
+			Snippet:     item.Content,
+			PublishedAt: chinaDate(item.Date),
+			Source:      item.Website,
+			Favicon:     item.Icon,
+		})
+	}
+
+	return results, nil
+}
+
+// qianfanRecency maps a time range to Baidu's search_recency_filter, which has
+// no day or custom values; those ranges are filtered by date afterwards
+func qianfanRecency(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay, PeriodWeek:
+		return "week"
+	case PeriodMonth:
+		return "month"
+	case PeriodYear:
+		return "year"
+	}
+	return ""
+}
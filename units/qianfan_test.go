@@ -0,0 +1,67 @@
+package units
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchWithQianfan(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeRange TimeRange
+		// recency is the expected search_recency_filter, nil when absent
+		recency interface{}
+		links   []string
+	}{
+		{
+			name:      "month is filtered by the service",
+			timeRange: TimeRange{Period: PeriodMonth},
+			recency:   "month",
+			links:     []string{"https://go.dev/doc/go1.23", "https://go.dev/doc/go1.22"},
+		},
+		{
+			name:      "custom range is filtered by date afterwards",
+			timeRange: TimeRange{Period: PeriodCustom, Start: time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)},
+			links:     []string{"https://go.dev/doc/go1.23"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			baseURL := serveJSONFixture(t, "/ai_search/web_search", "qianfan.json", func(r *http.Request, b map[string]interface{}) {
+				if got := r.Header.Get("Authorization"); got != "Bearer qianfan-test" {
+					t.Errorf("Authorization = %q", got)
+				}
+				body = b
+			})
+			t.Setenv("SEARCH_SERVICE", "qianfan")
+			t.Setenv("QIANFAN_BASE_URL", baseURL)
+			t.Setenv("QIANFAN_KEY", "qianfan-test")
+			t.Setenv("MAX_RESULTS", "5")
+
+			results, err := SearchResults("Go 迭代器", SearchOptions{TimeRange: tt.timeRange})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if body["search_source"] != "baidu_search_v2" || body["search_recency_filter"] != tt.recency {
+				t.Errorf("request body = %v", body)
+			}
+			wantFilter := []interface{}{map[string]interface{}{"type": "web", "top_k": float64(5)}}
+			if !reflect.DeepEqual(body["resource_type_filter"], wantFilter) {
+				t.Errorf("resource_type_filter = %v, want %v", body["resource_type_filter"], wantFilter)
+			}
+
+			if links := resultLinks(results); !reflect.DeepEqual(links, tt.links) {
+				t.Fatalf("links = %q, want %q", links, tt.links)
+			}
+			if first := results[0]; first.PublishedAt != "2024-08-13T02:00:00Z" || first.Source != "Go" ||
+				first.Favicon != "https://go.dev/images/favicon-gopher.svg" {
+				t.Errorf("first result = %+v", first)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package units
+
+import (
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// contextWeight scales conversation terms relative to query terms
+	contextWeight = 0.3
+)
+
+// Rerank reorders results with the reranker selected by RERANK and trims them to limit.
+// Results are returned unchanged apart from trimming when reranking is disabled.
+func Rerank(results []SearchResult, query string, opts SearchOptions, limit int) []SearchResult {
+	switch os.Getenv("RERANK") {
+	case "bm25":
+		results = RerankBM25(results, query, opts.Context)
+	case "embedding":
+		ranked, err := RerankEmbedding(results, query, opts)
+		if err != nil {
+			// Fall back to lexical ranking rather than failing the search
+			log.Printf("向量重排序失败，使用 BM25: %v", err)
+			ranked = RerankBM25(results, query, opts.Context)
+		}
+		results = ranked
+	}
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// RerankBM25 scores each result's title, snippet and crawled content against
+// the query and conversation context, and sorts them by descending score
+func RerankBM25(results []SearchResult, query string, context string) []SearchResult {
+	if len(results) == 0 {
+		return results
+	}
+
+	weights := make(map[string]float64)
+	for _, term := range tokenize(context) {
+		weights[term] = contextWeight
+	}
+	for _, term := range tokenize(query) {
+		weights[term] = 1
+	}
+	if len(weights) == 0 {
+		return results
+	}
+
+	// Term frequencies per document and document frequencies
+	docs := make([]map[string]int, len(results))
+	lengths := make([]int, len(results))
+	df := make(map[string]int)
+	totalLength := 0
+	for i, result := range results {
+		terms := tokenize(result.Title + " " + result.Snippet + " " + result.Content)
+		tf := make(map[string]int)
+		for _, term := range terms {
+			tf[term]++
+		}
+		for term := range tf {
+			df[term]++
+		}
+		docs[i] = tf
+		lengths[i] = len(terms)
+		totalLength += len(terms)
+	}
+	avgLength := float64(totalLength) / float64(len(results))
+	if avgLength == 0 {
+		avgLength = 1
+	}
+
+	n := float64(len(results))
+	scored := make([]SearchResult, len(results))
+	for i, result := range results {
+		score := 0.0
+		for term, weight := range weights {
+			freq := float64(docs[i][term])
+			if freq == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[term])+0.5)/(float64(df[term])+0.5))
+			norm := freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/avgLength))
+			score += weight * idf * norm
+		}
+		result.Score = math.Round(score*1000) / 1000
+		scored[i] = result
+	}
+
+	// Stable sort keeps the provider's order for equal scores
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+	return scored
+}
+
+// tokenize lowercases text and splits it into terms. Runs of CJK characters,
+// which are not separated by spaces, are split into overlapping bigrams.
+func tokenize(text string) []string {
+	var terms []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			terms = append(terms, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				terms = append(terms, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
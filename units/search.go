@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 // SearchResult represents a single search result
@@ -14,15 +16,82 @@ type SearchResult struct {
 	Title   string `json:"title"`
 	Link    string `json:"link"`
 	Snippet string `json:"snippet"`
+	// PublishedAt is the publication date, normalised to RFC 3339 when it can be parsed
+	PublishedAt string `json:"published_at,omitempty"`
+	// Source is the site or outlet name displayed by the provider
+	Source    string     `json:"source,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	Favicon   string     `json:"favicon,omitempty"`
+	Thumbnail string     `json:"thumbnail,omitempty"`
+	Language  string     `json:"language,omitempty"`
+	Sitelinks []Sitelink `json:"sitelinks,omitempty"`
+	// Position is the 1-based rank assigned by the provider
+	Position int `json:"position,omitempty"`
+	// Provider is the search service, Engine the upstream engine for meta search services
+	Provider string `json:"provider,omitempty"`
+	Engine   string `json:"engine,omitempty"`
+	// Score is the relevance assigned by the reranker, if any
+	Score float64 `json:"score,omitempty"`
+	// Content holds crawled page text used for reranking; it is not serialised
+	Content string `json:"-"`
+}
+
+// Sitelink is a sub-page link shown beneath a result
+type Sitelink struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+}
+
+// SearchOptions customises a search
+type SearchOptions struct {
+	// Context is conversation text, such as the latest user message, used when reranking
+	Context string
+	// APIKey authenticates embedding requests when EMBEDDING_API_KEY is not set
+	APIKey string
+	// TimeRange restricts results by publication date
+	TimeRange TimeRange
+	// IncludeDomains limits results to these domains and their subdomains
+	IncludeDomains []string
+	// ExcludeDomains removes results from these domains, in addition to BLOCKED_DOMAINS
+	ExcludeDomains []string
+	// Unranked returns the whole candidate pool without reranking or trimming,
+	// for callers that merge several searches and rerank them once
+	Unranked bool
 }
 
 // SearchResponse represents the response from a search
 type SearchResponse struct {
 	Results []SearchResult `json:"results"`
+	// Queries lists the queries actually searched when the original was rewritten
+	Queries []string `json:"queries,omitempty"`
+	// Answer is a summary generated by the search service, for services that offer one
+	Answer string `json:"answer,omitempty"`
 }
 
 // Search performs a search using the configured search service
 func Search(query string) (string, error) {
+	response, err := SearchWithAnswer(query, SearchOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(response)
+	if err != nil {
+		return "", fmt.Errorf("JSON编码失败: %v", err)
+	}
+
+	return string(jsonData), nil
+}
+
+// SearchResults performs a search and returns the typed results
+func SearchResults(query string, opts SearchOptions) ([]SearchResult, error) {
+	response, err := SearchWithAnswer(query, opts)
+	return response.Results, err
+}
+
+// SearchWithAnswer performs a search and also returns the answer generated by
+// services that offer one
+func SearchWithAnswer(query string, opts SearchOptions) (SearchResponse, error) {
 	fmt.Printf("正在使用查询进行自定义搜索: %s\n", query)
 
 	searchService := os.Getenv("SEARCH_SERVICE")
@@ -30,52 +99,78 @@ func Search(query string) (string, error) {
 		searchService = "duckduckgo" // Default to DuckDuckGo
 	}
 
+	include := normalizeDomains(opts.IncludeDomains)
+	exclude := normalizeDomains(append(blockedDomains(), opts.ExcludeDomains...))
+	providerQuery := withDomainOperators(query, include, exclude)
+	if nativeDomainFilter(searchService) {
+		providerQuery = query
+	}
+	opts.IncludeDomains, opts.ExcludeDomains = include, exclude
+
 	var results []SearchResult
+	var answer string
 	var err error
 
 	switch searchService {
 	case "search1api":
-		results, err = searchWithSearch1API(query)
+		results, err = searchWithSearch1API(providerQuery, opts)
 	case "google":
-		results, err = searchWithGoogle(query)
+		results, err = searchWithGoogle(providerQuery, opts)
 	case "bing":
-		results, err = searchWithBing(query)
+		results, err = searchWithBing(providerQuery, opts)
 	case "serpapi":
-		results, err = searchWithSerpAPI(query)
+		results, err = searchWithSerpAPI(providerQuery, opts)
 	case "serper":
-		results, err = searchWithSerper(query)
+		results, err = searchWithSerper(providerQuery, opts)
 	case "duckduckgo":
-		results, err = searchWithDuckDuckGo(query)
+		results, err = searchWithDuckDuckGo(providerQuery, opts)
 	case "searxng":
-		results, err = searchWithSearXNG(query)
+		results, err = searchWithSearXNG(providerQuery, opts)
+	case "brave":
+		results, err = searchWithBrave(providerQuery, opts)
+	case "tavily":
+		results, answer, err = searchWithTavily(providerQuery, opts)
+	case "exa":
+		results, err = searchWithExa(providerQuery, opts)
+	case "bocha":
+		results, err = searchWithBocha(providerQuery, opts)
+	case "qianfan":
+		results, err = searchWithQianfan(providerQuery, opts)
+	case "zhipu":
+		results, err = searchWithZhipu(providerQuery, opts)
 	default:
-		return "", fmt.Errorf("不支持的搜索服务: %s", searchService)
+		return SearchResponse{}, fmt.Errorf("不支持的搜索服务: %s", searchService)
 	}
 
 	if err != nil {
-		return "", fmt.Errorf("搜索失败: %v", err)
+		return SearchResponse{}, fmt.Errorf("搜索失败: %v", err)
 	}
 
-	response := SearchResponse{Results: results}
-	jsonData, err := json.Marshal(response)
-	if err != nil {
-		return "", fmt.Errorf("JSON编码失败: %v", err)
+	enrichResults(results, searchService)
+	results = filterByDomain(results, include, exclude)
+	if !nativeTimeRange(searchService, opts.TimeRange) {
+		results = filterByTimeRange(results, opts.TimeRange)
+	}
+	if !opts.Unranked {
+		results = Rerank(results, query, opts, MaxResults())
 	}
 
 	fmt.Println("自定义搜索服务调用完成")
-	return string(jsonData), nil
+	return SearchResponse{Results: results, Answer: answer}, nil
+}
+
+func searchWithSearch1API(query string, opts SearchOptions) ([]SearchResult, error) {
+	return querySearch1API("search", query)
 }
 
-func searchWithSearch1API(query string) ([]SearchResult, error) {
+// querySearch1API calls a Search1API endpoint, such as search or news
+func querySearch1API(endpoint, query string) ([]SearchResult, error) {
 	apiKey := os.Getenv("SEARCH1API_KEY")
-	maxResults := os.Getenv("MAX_RESULTS")
-	if maxResults == "" {
-		maxResults = "10"
-	}
+	maxResults := CandidateCount()
 
 	reqBody := map[string]string{
 		"query":         query,
-		"max_results":   maxResults,
+		"max_results":   fmt.Sprintf("%d", maxResults),
 		"crawl_results": "0",
 	}
 
@@ -84,7 +179,7 @@ func searchWithSearch1API(query string) ([]SearchResult, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", "https://api.search1api.com/search/", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", "https://api.search1api.com/"+endpoint+"/", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +189,7 @@ func searchWithSearch1API(query string) ([]SearchResult, error) {
 		req.Header.Set("Authorization", "Bearer "+apiKey)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := getHTTPClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -108,30 +203,86 @@ func searchWithSearch1API(query string) ([]SearchResult, error) {
 	return response.Results, nil
 }
 
-func searchWithGoogle(query string) ([]SearchResult, error) {
+func searchWithGoogle(query string, opts SearchOptions) ([]SearchResult, error) {
+	return queryGoogleCSE(query, opts, "")
+}
+
+// Google Custom Search returns at most 10 results per request and 100 per query
+const (
+	googlePageSize   = 10
+	googleMaxResults = 100
+)
+
+// queryGoogleCSE calls the Custom Search API, optionally with a sort expression.
+// Larger candidate pools are fetched page by page, each page costing one query.
+func queryGoogleCSE(query string, opts SearchOptions, sort string) ([]SearchResult, error) {
 	cx := os.Getenv("GOOGLE_CX")
 	apiKey := os.Getenv("GOOGLE_KEY")
-	maxResults := os.Getenv("MAX_RESULTS")
-	if maxResults == "" {
-		maxResults = "10"
-	}
+	maxResults := min(CandidateCount(), googleMaxResults)
 
-	apiURL := fmt.Sprintf("https://www.googleapis.com/customsearch/v1?cx=%s&key=%s&q=%s",
+	apiURL := fmt.Sprintf("%s?cx=%s&key=%s&q=%s",
+		envBaseURL("GOOGLE_BASE_URL", "https://www.googleapis.com/customsearch/v1"),
 		url.QueryEscape(cx),
 		url.QueryEscape(apiKey),
 		url.QueryEscape(query))
+	switch opts.TimeRange.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear:
+		apiURL += "&dateRestrict=" + opts.TimeRange.Period[:1] + "1"
+	case PeriodCustom:
+		// A custom range is itself a sort expression, so it only applies when no other sort is requested
+		if sort == "" {
+			start, end := opts.TimeRange.customDates("20060102", time.Now())
+			sort = "date:r:" + start + ":" + end
+		}
+	}
+	if sort != "" {
+		apiURL += "&sort=" + url.QueryEscape(sort)
+	}
+
+	var results []SearchResult
+	for start := 1; len(results) < maxResults; start += googlePageSize {
+		num := min(maxResults-len(results), googlePageSize)
+		page, err := googleCSEPage(fmt.Sprintf("%s&num=%d&start=%d", apiURL, num, start))
+		if err != nil {
+			// Later pages only widen the candidate pool, so keep what was fetched
+			if len(results) > 0 {
+				break
+			}
+			return nil, err
+		}
+		results = append(results, page...)
+		if len(page) < num {
+			break
+		}
+	}
 
-	resp, err := http.Get(apiURL)
+	return results[:min(len(results), maxResults)], nil
+}
+
+// googleCSEPage fetches and converts one page of Custom Search results
+func googleCSEPage(apiURL string) ([]SearchResult, error) {
+	resp, err := getHTTPClient().Get(apiURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
 	var googleResp struct {
 		Items []struct {
-			Title   string `json:"title"`
-			Link    string `json:"link"`
-			Snippet string `json:"snippet"`
+			Title       string `json:"title"`
+			Link        string `json:"link"`
+			Snippet     string `json:"snippet"`
+			DisplayLink string `json:"displayLink"`
+			Pagemap     struct {
+				Metatags     []map[string]interface{} `json:"metatags"`
+				CSEThumbnail []struct {
+					Src string `json:"src"`
+				} `json:"cse_thumbnail"`
+			} `json:"pagemap"`
 		} `json:"items"`
 	}
 
@@ -141,31 +292,46 @@ func searchWithGoogle(query string) ([]SearchResult, error) {
 
 	var results []SearchResult
 	for _, item := range googleResp.Items {
-		results = append(results, SearchResult{
+		result := SearchResult{
 			Title:   item.Title,
 			Link:    item.Link,
 			Snippet: item.Snippet,
-		})
+			Source:  item.DisplayLink,
+		}
+		if len(item.Pagemap.CSEThumbnail) > 0 {
+			result.Thumbnail = item.Pagemap.CSEThumbnail[0].Src
+		}
+		if len(item.Pagemap.Metatags) > 0 {
+			tags := item.Pagemap.Metatags[0]
+			result.PublishedAt = firstString(tags, "article:published_time", "og:updated_time", "date", "pubdate")
+			result.Language = firstString(tags, "og:locale", "language")
+			if siteName := firstString(tags, "og:site_name"); siteName != "" {
+				result.Source = siteName
+			}
+		}
+		results = append(results, result)
 	}
 
-	return results[:min(len(results), parseInt(maxResults))], nil
+	return results, nil
 }
 
-func searchWithBing(query string) ([]SearchResult, error) {
+func searchWithBing(query string, opts SearchOptions) ([]SearchResult, error) {
 	apiKey := os.Getenv("BING_KEY")
-	maxResults := os.Getenv("MAX_RESULTS")
-	if maxResults == "" {
-		maxResults = "10"
+	maxResults := CandidateCount()
+
+	apiURL := fmt.Sprintf("https://api.bing.microsoft.com/v7.0/search?q=%s&count=%d", url.QueryEscape(query), min(maxResults, 50))
+	if freshness := bingFreshness(opts.TimeRange); freshness != "" {
+		apiURL += "&freshness=" + url.QueryEscape(freshness)
 	}
 
-	req, err := http.NewRequest("GET", "https://api.bing.microsoft.com/v7.0/search?q="+url.QueryEscape(query), nil)
+	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Ocp-Apim-Subscription-Key", apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := getHTTPClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -174,9 +340,18 @@ func searchWithBing(query string) ([]SearchResult, error) {
 	var bingResp struct {
 		WebPages struct {
 			Value []struct {
-				Name    string `json:"name"`
-				URL     string `json:"url"`
-				Snippet string `json:"snippet"`
+				Name            string `json:"name"`
+				URL             string `json:"url"`
+				Snippet         string `json:"snippet"`
+				DisplayURL      string `json:"displayUrl"`
+				DatePublished   string `json:"datePublished"`
+				DateLastCrawled string `json:"dateLastCrawled"`
+				Language        string `json:"language"`
+				ThumbnailURL    string `json:"thumbnailUrl"`
+				DeepLinks       []struct {
+					Name string `json:"name"`
+					URL  string `json:"url"`
+				} `json:"deepLinks"`
 			} `json:"value"`
 		} `json:"webPages"`
 	}
@@ -187,28 +362,36 @@ func searchWithBing(query string) ([]SearchResult, error) {
 
 	var results []SearchResult
 	for _, item := range bingResp.WebPages.Value {
-		results = append(results, SearchResult{
-			Title:   item.Name,
-			Link:    item.URL,
-			Snippet: item.Snippet,
-		})
+		result := SearchResult{
+			Title:       item.Name,
+			Link:        item.URL,
+			Snippet:     item.Snippet,
+			PublishedAt: item.DatePublished,
+			Language:    item.Language,
+			Thumbnail:   item.ThumbnailURL,
+		}
+		for _, link := range item.DeepLinks {
+			result.Sitelinks = append(result.Sitelinks, Sitelink{Title: link.Name, Link: link.URL})
+		}
+		results = append(results, result)
 	}
 
-	return results[:min(len(results), parseInt(maxResults))], nil
+	return results[:min(len(results), maxResults)], nil
 }
 
-func searchWithSerpAPI(query string) ([]SearchResult, error) {
+func searchWithSerpAPI(query string, opts SearchOptions) ([]SearchResult, error) {
 	apiKey := os.Getenv("SERPAPI_KEY")
-	maxResults := os.Getenv("MAX_RESULTS")
-	if maxResults == "" {
-		maxResults = "10"
-	}
+	maxResults := CandidateCount()
 
-	apiURL := fmt.Sprintf("https://serpapi.com/search?api_key=%s&engine=google&q=%s&google_domain=google.com",
+	apiURL := fmt.Sprintf("https://serpapi.com/search?api_key=%s&engine=google&q=%s&google_domain=google.com&num=%d",
 		url.QueryEscape(apiKey),
-		url.QueryEscape(query))
+		url.QueryEscape(query),
+		min(maxResults, 100))
+	if tbs := googleTBS(opts.TimeRange); tbs != "" {
+		apiURL += "&tbs=" + url.QueryEscape(tbs)
+	}
 
-	resp, err := http.Get(apiURL)
+	resp, err := getHTTPClient().Get(apiURL)
 	if err != nil {
 		return nil, err
 	}
@@ -216,9 +399,19 @@ func searchWithSerpAPI(query string) ([]SearchResult, error) {
 
 	var serpResp struct {
 		Organic []struct {
-			Title   string `json:"title"`
-			Link    string `json:"link"`
-			Snippet string `json:"snippet"`
+			Position      int    `json:"position"`
+			Title         string `json:"title"`
+			Link          string `json:"link"`
+			Snippet       string `json:"snippet"`
+			Source        string `json:"source"`
+			DisplayedLink string `json:"displayed_link"`
+			Date          string `json:"date"`
+			Thumbnail     string `json:"thumbnail"`
+			Favicon       string `json:"favicon"`
+			Sitelinks     struct {
+				Inline   []Sitelink `json:"inline"`
+				Expanded []Sitelink `json:"expanded"`
+			} `json:"sitelinks"`
 		} `json:"organic_results"`
 	}
 
@@ -229,16 +422,22 @@ func searchWithSerpAPI(query string) ([]SearchResult, error) {
 	var results []SearchResult
 	for _, item := range serpResp.Organic {
 		results = append(results, SearchResult{
-			Title:   item.Title,
-			Link:    item.Link,
-			Snippet: item.Snippet,
+			Title:       item.Title,
+			Link:        item.Link,
+			Snippet:     item.Snippet,
+			PublishedAt: item.Date,
+			Source:      item.Source,
+			Favicon:     item.Favicon,
+			Thumbnail:   item.Thumbnail,
+			Sitelinks:   append(item.Sitelinks.Inline, item.Sitelinks.Expanded...),
+			Position:    item.Position,
 		})
 	}
 
-	return results[:min(len(results), parseInt(maxResults))], nil
+	return results[:min(len(results), maxResults)], nil
 }
 
-func searchWithSerper(query string) ([]SearchResult, error) {
+func searchWithSerper(query string, opts SearchOptions) ([]SearchResult, error) {
 	apiKey := os.Getenv("SERPER_KEY")
 	gl := os.Getenv("GL")
 	if gl == "" {
@@ -249,10 +448,16 @@ func searchWithSerper(query string) ([]SearchResult, error) {
 		hl = "en"
 	}
 
+	maxResults := CandidateCount()
+
 	reqBody := map[string]string{
-		"q":  query,
-		"gl": gl,
-		"hl": hl,
+		"q":   query,
+		"gl":  gl,
+		"hl":  hl,
+		"num": fmt.Sprintf("%d", min(maxResults, 100)),
+	}
+	if tbs := googleTBS(opts.TimeRange); tbs != "" {
+		reqBody["tbs"] = tbs
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -268,7 +473,7 @@ func searchWithSerper(query string) ([]SearchResult, error) {
 	req.Header.Set("X-API-KEY", apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := getHTTPClient().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -276,9 +481,13 @@ func searchWithSerper(query string) ([]SearchResult, error) {
 
 	var serperResp struct {
 		Organic []struct {
-			Title   string `json:"title"`
-			Link    string `json:"link"`
-			Snippet string `json:"snippet"`
+			Title     string     `json:"title"`
+			Link      string     `json:"link"`
+			Snippet   string     `json:"snippet"`
+			Date      string     `json:"date"`
+			Position  int        `json:"position"`
+			ImageURL  string     `json:"imageUrl"`
+			Sitelinks []Sitelink `json:"sitelinks"`
 		} `json:"organic"`
 	}
 
@@ -286,108 +495,215 @@ func searchWithSerper(query string) ([]SearchResult, error) {
 		return nil, err
 	}
 
-	maxResults := parseInt(os.Getenv("MAX_RESULTS"))
-	if maxResults == 0 {
-		maxResults = 10
-	}
-
 	var results []SearchResult
 	for _, item := range serperResp.Organic {
 		results = append(results, SearchResult{
-			Title:   item.Title,
-			Link:    item.Link,
-			Snippet: item.Snippet,
+			Title:       item.Title,
+			Link:        item.Link,
+			Snippet:     item.Snippet,
+			PublishedAt: item.Date,
+			Thumbnail:   item.ImageURL,
+			Sitelinks:   item.Sitelinks,
+			Position:    item.Position,
+			Language:    hl,
 		})
 	}
 
 	return results[:min(len(results), maxResults)], nil
 }
 
-func searchWithDuckDuckGo(query string) ([]SearchResult, error) {
-	maxResults := os.Getenv("MAX_RESULTS")
-	if maxResults == "" {
-		maxResults = "10"
-	}
+func searchWithSearXNG(query string, opts SearchOptions) ([]SearchResult, error) {
+	return querySearXNG(query, opts, "general")
+}
 
-	reqBody := map[string]string{
-		"q":           query,
-		"max_results": maxResults,
-	}
+// querySearXNG searches a SearXNG category, such as general or news
+func querySearXNG(query string, opts SearchOptions, category string) ([]SearchResult, error) {
+	baseURL := os.Getenv("SEARXNG_BASE_URL")
+	maxResults := CandidateCount()
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
+	apiURL := fmt.Sprintf("%s/search?q=%s&categories=%s&format=json",
+		baseURL,
+		url.QueryEscape(query),
+		url.QueryEscape(category))
+	if nativeTimeRange("searxng", opts.TimeRange) && !opts.TimeRange.IsZero() {
+		apiURL += "&time_range=" + opts.TimeRange.Period
 	}
 
-	resp, err := http.Post("https://ddg.search2ai.online/search", "application/json", bytes.NewBuffer(jsonData))
+	resp, err := getHTTPClient().Get(apiURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	var duckResp struct {
+	var searxResp struct {
 		Results []struct {
-			Title string `json:"title"`
-			Href  string `json:"href"`
-			Body  string `json:"body"`
+			Title         string   `json:"title"`
+			URL           string   `json:"url"`
+			Content       string   `json:"content"`
+			Engine        string   `json:"engine"`
+			Engines       []string `json:"engines"`
+			PublishedDate string   `json:"publishedDate"`
+			Thumbnail     string   `json:"thumbnail"`
+			ImgSrc        string   `json:"img_src"`
 		} `json:"results"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&duckResp); err != nil {
+	if err := json.NewDecoder(resp.Body).Decode(&searxResp); err != nil {
 		return nil, err
 	}
 
 	var results []SearchResult
-	for _, item := range duckResp.Results {
+	for _, item := range searxResp.Results {
+		engine := item.Engine
+		if len(item.Engines) > 0 {
+			engine = strings.Join(item.Engines, ",")
+		}
+		thumbnail := item.Thumbnail
+		if thumbnail == "" {
+			thumbnail = item.ImgSrc
+		}
 		results = append(results, SearchResult{
-			Title:   item.Title,
-			Link:    item.Href,
-			Snippet: item.Body,
+			Title:       item.Title,
+			Link:        item.URL,
+			Snippet:     item.Content,
+			PublishedAt: item.PublishedDate,
+			Thumbnail:   thumbnail,
+			Engine:      engine,
 		})
 	}
 
-	return results, nil
+	return results[:min(len(results), maxResults)], nil
 }
 
-func searchWithSearXNG(query string) ([]SearchResult, error) {
-	baseURL := os.Getenv("SEARXNG_BASE_URL")
-	maxResults := parseInt(os.Getenv("MAX_RESULTS"))
-	if maxResults == 0 {
-		maxResults = 10
+// nativeTimeRange reports whether a service can apply the time range itself;
+// other services are filtered by publication date afterwards
+func nativeTimeRange(service string, tr TimeRange) bool {
+	switch service {
+	case "google", "bing", "serpapi", "serper", "brave", "tavily", "exa", "bocha":
+		return true
+	case "searxng", "zhipu", "duckduckgo":
+		return tr.Period != PeriodCustom
+	case "qianfan":
+		return tr.Period != PeriodDay && tr.Period != PeriodCustom
+	}
+	return tr.IsZero()
+}
+
+// nativeDomainFilter reports whether a service takes include and exclude domain
+// lists as parameters rather than site: operators in the query. Zhipu is
+// included because its 70-character query limit leaves no room for operators;
+// it takes a single include domain natively and the rest are post-filtered.
+func nativeDomainFilter(service string) bool {
+	switch service {
+	case "tavily", "exa", "bocha", "zhipu":
+		return true
 	}
+	return false
+}
 
-	apiURL := fmt.Sprintf("%s/search?q=%s&category=general&format=json",
-		baseURL,
-		url.QueryEscape(query))
+// bingFreshness maps a time range to Bing's freshness parameter
+func bingFreshness(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay:
+		return "Day"
+	case PeriodWeek:
+		return "Week"
+	case PeriodMonth:
+		return "Month"
+	case PeriodYear:
+		// Bing has no year value, so use an explicit date range
+		now := time.Now()
+		start, _ := tr.Bounds(now)
+		return start.Format("2006-01-02") + ".." + now.Format("2006-01-02")
+	case PeriodCustom:
+		start, end := tr.customDates("2006-01-02", time.Now())
+		return start + ".." + end
+	}
+	return ""
+}
 
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		return nil, err
+// googleTBS maps a time range to the tbs parameter used by SerpAPI and Serper
+func googleTBS(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear:
+		return "qdr:" + tr.Period[:1]
+	case PeriodCustom:
+		start, end := tr.customDates("1/2/2006", time.Now())
+		return "cdr:1,cd_min:" + start + ",cd_max:" + end
 	}
-	defer resp.Body.Close()
+	return ""
+}
 
-	var searxResp struct {
-		Results []struct {
-			Title   string `json:"title"`
-			URL     string `json:"url"`
-			Content string `json:"content"`
-		} `json:"results"`
+// enrichResults fills in metadata every provider can supply: the provider name,
+// positions, domains, favicons and normalised publication dates
+func enrichResults(results []SearchResult, provider string) {
+	for i := range results {
+		result := &results[i]
+		result.Provider = provider
+		if result.Position == 0 {
+			result.Position = i + 1
+		}
+		if u, err := url.Parse(result.Link); err == nil && u.Host != "" {
+			if result.Domain == "" {
+				result.Domain = strings.TrimPrefix(u.Hostname(), "www.")
+			}
+			if result.Favicon == "" {
+				result.Favicon = u.Scheme + "://" + u.Host + "/favicon.ico"
+			}
+		}
+		if result.Source == "" {
+			result.Source = result.Domain
+		}
+		if result.PublishedAt != "" {
+			result.PublishedAt = normalizeDate(result.PublishedAt)
+		}
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&searxResp); err != nil {
-		return nil, err
+// envBaseURL returns an API base URL, overridable through env so a mirror,
+// proxy or test server can be used
+func envBaseURL(env, def string) string {
+	if value := os.Getenv(env); value != "" {
+		return strings.TrimSuffix(value, "/")
 	}
+	return def
+}
 
-	var results []SearchResult
-	for _, item := range searxResp.Results {
-		results = append(results, SearchResult{
-			Title:   item.Title,
-			Link:    item.URL,
-			Snippet: item.Content,
-		})
+// firstString returns the first non-empty string value among keys
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := m[key].(string); ok && value != "" {
+			return value
+		}
 	}
+	return ""
+}
 
-	return results[:min(len(results), maxResults)], nil
+// CandidateCount returns how many results to request from a search service.
+// When RERANK is set a larger pool is fetched, RERANK_CANDIDATES or three times
+// MAX_RESULTS, so the reranker can promote lower-ranked hits; Rerank trims it.
+func CandidateCount() int {
+	maxResults := MaxResults()
+	if os.Getenv("RERANK") == "" {
+		return maxResults
+	}
+	candidates := parseInt(os.Getenv("RERANK_CANDIDATES"))
+	if candidates <= 0 {
+		candidates = maxResults * 3
+	}
+	if candidates < maxResults {
+		candidates = maxResults
+	}
+	return candidates
+}
+
+// MaxResults returns the configured maximum number of search results
+func MaxResults() int {
+	maxResults := parseInt(os.Getenv("MAX_RESULTS"))
+	if maxResults <= 0 {
+		maxResults = 10
+	}
+	return maxResults
 }
 
 // Helper functions
@@ -0,0 +1,141 @@
+package units
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveJSONFixture serves a recorded JSON response at path and returns the
+// server URL. check inspects each request and its decoded JSON body, which is
+// nil for requests without one.
+func serveJSONFixture(t *testing.T, path, fixture string, check func(r *http.Request, body map[string]interface{})) string {
+	t.Helper()
+	data := readFixture(t, fixture)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]interface{}
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("request body: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		check(r, body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("BLOCKED_DOMAINS", "")
+	t.Setenv("RERANK", "")
+	return srv.URL
+}
+
+// serveSearXNG serves the recorded SearXNG response and selects it as the search service
+func serveSearXNG(t *testing.T) {
+	t.Helper()
+	data := readFixture(t, "searxng.json")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	t.Setenv("SEARCH_SERVICE", "searxng")
+	t.Setenv("SEARXNG_BASE_URL", srv.URL)
+	t.Setenv("BLOCKED_DOMAINS", "")
+	t.Setenv("MAX_RESULTS", "2")
+	t.Setenv("RERANK_CANDIDATES", "")
+}
+
+func TestSearchRerankCandidatePool(t *testing.T) {
+	const rustBook = "https://doc.rust-lang.org/book/ch04-02-references-and-borrowing.html"
+
+	tests := []struct {
+		name     string
+		rerank   string
+		unranked bool
+		links    []string
+	}{
+		{
+			name:  "without reranking the provider order is kept",
+			links: []string{"https://example.com/cooking", "https://example.com/travel"},
+		},
+		{
+			name:   "reranking promotes a hit beyond MAX_RESULTS",
+			rerank: "bm25",
+			links:  []string{rustBook, "https://example.com/cooking"},
+		},
+		{
+			name:     "unranked returns the candidate pool",
+			rerank:   "bm25",
+			unranked: true,
+			links: []string{
+				"https://example.com/cooking",
+				"https://example.com/travel",
+				"https://example.com/garden",
+				"https://example.com/cars",
+				rustBook,
+				"https://example.com/music",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serveSearXNG(t)
+			t.Setenv("RERANK", tt.rerank)
+
+			results, err := SearchResults("rust borrow checker", SearchOptions{Unranked: tt.unranked})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var links []string
+			for _, result := range results {
+				links = append(links, result.Link)
+			}
+			if len(links) != len(tt.links) {
+				t.Fatalf("links = %q, want %q", links, tt.links)
+			}
+			for i := range links {
+				if links[i] != tt.links[i] {
+					t.Errorf("links = %q, want %q", links, tt.links)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestCandidateCount(t *testing.T) {
+	tests := []struct {
+		rerank, maxResults, candidates string
+		want                           int
+	}{
+		{"", "10", "50", 10},
+		{"bm25", "10", "", 30},
+		{"embedding", "", "", 30},
+		{"bm25", "10", "25", 25},
+		{"bm25", "10", "5", 10},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("RERANK", tt.rerank)
+		t.Setenv("MAX_RESULTS", tt.maxResults)
+		t.Setenv("RERANK_CANDIDATES", tt.candidates)
+		if got := CandidateCount(); got != tt.want {
+			t.Errorf("CandidateCount() with RERANK=%q MAX_RESULTS=%q RERANK_CANDIDATES=%q = %d, want %d",
+				tt.rerank, tt.maxResults, tt.candidates, got, tt.want)
+		}
+	}
+}
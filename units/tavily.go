@@ -0,0 +1,106 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+func searchWithTavily(query string, opts SearchOptions) ([]SearchResult, string, error) {
+	apiKey := os.Getenv("TAVILY_KEY")
+	searchDepth := os.Getenv("TAVILY_SEARCH_DEPTH")
+	if searchDepth == "" {
+		searchDepth = "basic"
+	}
+
+	reqBody := map[string]interface{}{
+		"query":               query,
+		"max_results":         min(CandidateCount(), 20),
+		"search_depth":        searchDepth,
+		"include_answer":      tavilyOption(os.Getenv("TAVILY_INCLUDE_ANSWER")),
+		"include_raw_content": tavilyOption(os.Getenv("TAVILY_INCLUDE_RAW_CONTENT")),
+		"include_favicon":     true,
+	}
+	if len(opts.IncludeDomains) > 0 {
+		reqBody["include_domains"] = opts.IncludeDomains
+	}
+	if len(opts.ExcludeDomains) > 0 {
+		reqBody["exclude_domains"] = opts.ExcludeDomains
+	}
+	switch opts.TimeRange.Period {
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear:
+		reqBody["time_range"] = opts.TimeRange.Period
+	case PeriodCustom:
+		reqBody["start_date"], reqBody["end_date"] = opts.TimeRange.customDates("2006-01-02", time.Now())
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequest("POST", envBaseURL("TAVILY_BASE_URL", "https://api.tavily.com")+"/search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var tavilyResp struct {
+		Answer  string `json:"answer"`
+		Results []struct {
+			Title         string  `json:"title"`
+			URL           string  `json:"url"`
+			Content       string  `json:"content"`
+			RawContent    string  `json:"raw_content"`
+			Score         float64 `json:"score"`
+			PublishedDate string  `json:"published_date"`
+			Favicon       string  `json:"favicon"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tavilyResp); err != nil {
+		return nil, "", err
+	}
+
+	var results []SearchResult
+	for _, item := range tavilyResp.Results {
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			Link:        item.URL,
+			Snippet:     item.Content,
+			PublishedAt: item.PublishedDate,
+			Favicon:     item.Favicon,
+			Score:       item.Score,
+			Content:     item.RawContent,
+		})
+	}
+
+	return results, tavilyResp.Answer, nil
+}
+
+// tavilyOption converts an env value to a Tavily option, which is either a
+// boolean or a mode name such as "advanced" or "markdown"
+func tavilyOption(value string) interface{} {
+	switch value {
+	case "", "false":
+		return false
+	case "true":
+		return true
+	}
+	return value
+}
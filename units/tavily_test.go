@@ -0,0 +1,86 @@
+package units
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSearchWithTavily(t *testing.T) {
+	var body map[string]interface{}
+	baseURL := serveJSONFixture(t, "/search", "tavily.json", func(r *http.Request, b map[string]interface{}) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tvly-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+		body = b
+	})
+	t.Setenv("SEARCH_SERVICE", "tavily")
+	t.Setenv("TAVILY_BASE_URL", baseURL+"/")
+	t.Setenv("TAVILY_KEY", "tvly-test")
+	t.Setenv("TAVILY_INCLUDE_ANSWER", "advanced")
+	t.Setenv("TAVILY_INCLUDE_RAW_CONTENT", "markdown")
+	t.Setenv("TAVILY_SEARCH_DEPTH", "")
+	t.Setenv("MAX_RESULTS", "5")
+
+	response, err := SearchWithAnswer("go 1.23 release", SearchOptions{
+		TimeRange:      TimeRange{Period: PeriodWeek},
+		IncludeDomains: []string{"go.dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantBody := map[string]interface{}{
+		"query":               "go 1.23 release",
+		"max_results":         float64(5),
+		"search_depth":        "basic",
+		"include_answer":      "advanced",
+		"include_raw_content": "markdown",
+		"include_favicon":     true,
+		"include_domains":     []interface{}{"go.dev"},
+		"time_range":          "week",
+	}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("request body = %v, want %v", body, wantBody)
+	}
+
+	if response.Answer != "Go 1.23 was released in August 2024 and added range-over-func iterators." {
+		t.Errorf("Answer = %q", response.Answer)
+	}
+	if len(response.Results) != 2 {
+		t.Fatalf("got %d results, want 2", len(response.Results))
+	}
+	first := response.Results[0]
+	if first.Content != "# Go 1.23 Release Notes\n\nRange over function types is now supported." {
+		t.Errorf("Content = %q, want the raw content", first.Content)
+	}
+	if first.Snippet != "The latest Go release, version 1.23, arrives six months after Go 1.22." ||
+		first.Score != 0.91 || first.Favicon != "https://go.dev/images/favicon-gopher.svg" ||
+		first.Provider != "tavily" || first.Domain != "go.dev" {
+		t.Errorf("first result = %+v", first)
+	}
+	if first.PublishedAt == "" || first.PublishedAt[:10] != "2024-08-13" {
+		t.Errorf("PublishedAt = %q", first.PublishedAt)
+	}
+	if second := response.Results[1]; second.Content != "" || second.Score != 0.72 {
+		t.Errorf("second result = %+v", second)
+	}
+}
+
+func TestTavilyOption(t *testing.T) {
+	tests := []struct {
+		value string
+		want  interface{}
+	}{
+		{"", false},
+		{"false", false},
+		{"true", true},
+		{"advanced", "advanced"},
+		{"text", "text"},
+	}
+	for _, tt := range tests {
+		if got := tavilyOption(tt.value); got != tt.want {
+			t.Errorf("tavilyOption(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
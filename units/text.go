@@ -0,0 +1,24 @@
+package units
+
+import (
+	"regexp"
+	"strings"
+)
+
+// htmlTag matches an HTML or XML tag, such as Stack Exchange post markup or the
+// JATS markup in Crossref abstracts
+var htmlTag = regexp.MustCompile(`<[^>]+>`)
+
+// TruncateText shortens s to at most n runes, marking the cut with an ellipsis
+func TruncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "…"
+}
+
+// collapseSpace joins whitespace runs, such as the line breaks in arXiv titles, into single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,118 @@
+package units
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time range periods accepted by the search tool
+const (
+	PeriodDay    = "day"
+	PeriodWeek   = "week"
+	PeriodMonth  = "month"
+	PeriodYear   = "year"
+	PeriodCustom = "custom"
+)
+
+// TimeRange restricts results by publication date
+type TimeRange struct {
+	// Period is day, week, month, year or custom; empty means no restriction
+	Period string
+	// Start and End bound a custom range; either may be zero
+	Start time.Time
+	End   time.Time
+}
+
+// ParseTimeRange validates a period and the YYYY-MM-DD dates of a custom range
+func ParseTimeRange(period, start, end string) (TimeRange, error) {
+	tr := TimeRange{Period: period}
+	switch period {
+	case "":
+		return tr, nil
+	case PeriodDay, PeriodWeek, PeriodMonth, PeriodYear:
+		return tr, nil
+	case PeriodCustom:
+	default:
+		return tr, fmt.Errorf("invalid time_range: %s", period)
+	}
+
+	var err error
+	if start != "" {
+		if tr.Start, err = time.Parse("2006-01-02", start); err != nil {
+			return tr, fmt.Errorf("invalid start_date: %s", start)
+		}
+	}
+	if end != "" {
+		if tr.End, err = time.Parse("2006-01-02", end); err != nil {
+			return tr, fmt.Errorf("invalid end_date: %s", end)
+		}
+	}
+	if tr.Start.IsZero() && tr.End.IsZero() {
+		return tr, fmt.Errorf("custom time_range requires start_date or end_date")
+	}
+	if !tr.Start.IsZero() && !tr.End.IsZero() && tr.End.Before(tr.Start) {
+		return tr, fmt.Errorf("end_date is before start_date")
+	}
+	return tr, nil
+}
+
+// IsZero reports whether the range places no restriction
+func (tr TimeRange) IsZero() bool {
+	return tr.Period == ""
+}
+
+// Bounds returns the start and end of the range relative to now.
+// A zero end means "until now".
+func (tr TimeRange) Bounds(now time.Time) (time.Time, time.Time) {
+	switch tr.Period {
+	case PeriodDay:
+		return now.AddDate(0, 0, -1), time.Time{}
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), time.Time{}
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0), time.Time{}
+	case PeriodYear:
+		return now.AddDate(-1, 0, 0), time.Time{}
+	case PeriodCustom:
+		end := tr.End
+		if !end.IsZero() {
+			// Include the whole end day
+			end = end.AddDate(0, 0, 1)
+		}
+		return tr.Start, end
+	}
+	return time.Time{}, time.Time{}
+}
+
+// customDates returns the custom range's dates in layout, substituting
+// fallbacks for open ends
+func (tr TimeRange) customDates(layout string, now time.Time) (string, string) {
+	start := tr.Start
+	if start.IsZero() {
+		start = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	end := tr.End
+	if end.IsZero() {
+		end = now
+	}
+	return start.Format(layout), end.Format(layout)
+}
+
+// filterByTimeRange drops results published outside the range. Results
+// without a parseable date are kept, since their age is unknown.
+func filterByTimeRange(results []SearchResult, tr TimeRange) []SearchResult {
+	if tr.IsZero() {
+		return results
+	}
+	start, end := tr.Bounds(time.Now())
+
+	var filtered []SearchResult
+	for _, result := range results {
+		published, ok := ParseDate(result.PublishedAt)
+		if ok && ((!start.IsZero() && published.Before(start)) || (!end.IsZero() && !published.Before(end))) {
+			continue
+		}
+		filtered = append(filtered, result)
+	}
+	return filtered
+}
@@ -0,0 +1,260 @@
+package units
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultWikipediaMaxChars bounds the Markdown returned for an article
+const defaultWikipediaMaxChars = 8000
+
+// WikiArticle is a MediaWiki article split into its lead and sections
+type WikiArticle struct {
+	Title    string        `json:"title"`
+	URL      string        `json:"url"`
+	Language string        `json:"language,omitempty"`
+	Summary  string        `json:"summary"`
+	Sections []WikiSection `json:"sections,omitempty"`
+	// Alternatives are other titles matching the query
+	Alternatives []string `json:"alternatives,omitempty"`
+}
+
+// WikiSection is a section of an article; Level 2 is a top-level "== Heading =="
+type WikiSection struct {
+	Title   string `json:"title"`
+	Level   int    `json:"level"`
+	Content string `json:"content"`
+}
+
+// wikipediaAPIURL returns the api.php endpoint for a language. WIKIPEDIA_BASE_URL may
+// contain a {lang} placeholder; a fixed URL serves internal wikis.
+func wikipediaAPIURL(language string) string {
+	if language == "" {
+		language = os.Getenv("WIKIPEDIA_LANGUAGE")
+	}
+	if language == "" {
+		language = "en"
+	}
+	baseURL := os.Getenv("WIKIPEDIA_BASE_URL")
+	if baseURL == "" {
+		baseURL = "https://{lang}.wikipedia.org/w/api.php"
+	}
+	return strings.ReplaceAll(baseURL, "{lang}", url.PathEscape(language))
+}
+
+// WikipediaLookup finds the article best matching query and fetches its plain text
+func WikipediaLookup(query, language string) (*WikiArticle, error) {
+	fmt.Printf("正在查询维基百科: %s\n", query)
+	apiURL := wikipediaAPIURL(language)
+
+	var searchResp struct {
+		Query struct {
+			Search []struct {
+				Title string `json:"title"`
+			} `json:"search"`
+		} `json:"query"`
+	}
+	params := url.Values{
+		"action":   {"query"},
+		"list":     {"search"},
+		"srsearch": {query},
+		"srlimit":  {"5"},
+	}
+	if err := wikiRequest(apiURL, params, &searchResp); err != nil {
+		return nil, fmt.Errorf("维基百科搜索失败: %v", err)
+	}
+	if len(searchResp.Query.Search) == 0 {
+		return nil, fmt.Errorf("维基百科中没有找到: %s", query)
+	}
+
+	title := searchResp.Query.Search[0].Title
+	var pageResp struct {
+		Query struct {
+			Pages []struct {
+				Title        string `json:"title"`
+				FullURL      string `json:"fullurl"`
+				Extract      string `json:"extract"`
+				PageLanguage string `json:"pagelanguage"`
+				Missing      bool   `json:"missing"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	params = url.Values{
+		"action":          {"query"},
+		"prop":            {"extracts|info"},
+		"inprop":          {"url"},
+		"explaintext":     {"1"},
+		"exsectionformat": {"wiki"},
+		"redirects":       {"1"},
+		"titles":          {title},
+	}
+	if err := wikiRequest(apiURL, params, &pageResp); err != nil {
+		return nil, fmt.Errorf("维基百科页面获取失败: %v", err)
+	}
+	if len(pageResp.Query.Pages) == 0 || pageResp.Query.Pages[0].Missing {
+		return nil, fmt.Errorf("维基百科页面不存在: %s", title)
+	}
+
+	page := pageResp.Query.Pages[0]
+	if page.Extract == "" {
+		return nil, fmt.Errorf("无法获取页面正文，该 wiki 可能未安装 TextExtracts 扩展: %s", page.Title)
+	}
+
+	article := &WikiArticle{
+		Title:    page.Title,
+		URL:      page.FullURL,
+		Language: page.PageLanguage,
+	}
+	article.Summary, article.Sections = splitWikiSections(page.Extract)
+	for _, result := range searchResp.Query.Search[1:] {
+		article.Alternatives = append(article.Alternatives, result.Title)
+	}
+
+	fmt.Println("维基百科查询完成")
+	return article, nil
+}
+
+// wikiRequest calls the MediaWiki action API and decodes its JSON response
+func wikiRequest(apiURL string, params url.Values, v interface{}) error {
+	params.Set("format", "json")
+	params.Set("formatversion", "2")
+
+	req, err := http.NewRequest("GET", apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	// Wikimedia asks API clients to identify themselves
+	req.Header.Set("User-Agent", "search4ai-go (https://github.com/liyown/search4ai-go)")
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var apiErr struct {
+		Error *struct {
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	body := json.NewDecoder(resp.Body)
+	var raw json.RawMessage
+	if err := body.Decode(&raw); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &apiErr); err == nil && apiErr.Error != nil {
+		return fmt.Errorf("%s", apiErr.Error.Info)
+	}
+	return json.Unmarshal(raw, v)
+}
+
+var wikiHeading = regexp.MustCompile(`(?m)^(={2,6})\s*(.+?)\s*={2,6}\s*$`)
+
+// splitWikiSections splits a plain-text extract with wiki-style headings into
+// the lead text and its sections
+func splitWikiSections(extract string) (string, []WikiSection) {
+	matches := wikiHeading.FindAllStringSubmatchIndex(extract, -1)
+	if len(matches) == 0 {
+		return strings.TrimSpace(extract), nil
+	}
+
+	summary := strings.TrimSpace(extract[:matches[0][0]])
+	var sections []WikiSection
+	for i, match := range matches {
+		end := len(extract)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		sections = append(sections, WikiSection{
+			Title:   extract[match[4]:match[5]],
+			Level:   match[3] - match[2],
+			Content: strings.TrimSpace(extract[match[1]:end]),
+		})
+	}
+	return summary, sections
+}
+
+// Markdown renders the article lead, or the named sections with their subsections,
+// truncated to WIKIPEDIA_MAX_CHARS
+func (a *WikiArticle) Markdown(sections []string) string {
+	maxChars := parseInt(os.Getenv("WIKIPEDIA_MAX_CHARS"))
+	if maxChars <= 0 {
+		maxChars = defaultWikipediaMaxChars
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n%s\n\n", a.Title, a.URL)
+
+	if len(sections) == 0 {
+		sb.WriteString(a.Summary)
+		if titles := a.sectionTitles(); len(titles) > 0 {
+			fmt.Fprintf(&sb, "\n\n章节：%s", strings.Join(titles, "、"))
+		}
+	} else {
+		var missing []string
+		for _, name := range sections {
+			text := a.sectionMarkdown(name)
+			if text == "" {
+				missing = append(missing, name)
+				continue
+			}
+			sb.WriteString(text)
+			sb.WriteString("\n\n")
+		}
+		if len(missing) > 0 {
+			fmt.Fprintf(&sb, "未找到章节：%s。可用章节：%s", strings.Join(missing, "、"), strings.Join(a.sectionTitles(), "、"))
+		}
+	}
+	if len(a.Alternatives) > 0 {
+		fmt.Fprintf(&sb, "\n\n其他相关条目：%s", strings.Join(a.Alternatives, "、"))
+	}
+
+	return TruncateText(strings.TrimSpace(sb.String()), maxChars)
+}
+
+// sectionTitles lists the top-level section titles, skipping sections with no text,
+// such as reference lists that plain-text extracts leave empty
+func (a *WikiArticle) sectionTitles() []string {
+	var titles []string
+	for i, section := range a.Sections {
+		if section.Level != 2 {
+			continue
+		}
+		hasSubsections := i+1 < len(a.Sections) && a.Sections[i+1].Level > 2
+		if section.Content != "" || hasSubsections {
+			titles = append(titles, section.Title)
+		}
+	}
+	return titles
+}
+
+// sectionMarkdown renders the first section titled name, case-insensitively, with its subsections
+func (a *WikiArticle) sectionMarkdown(name string) string {
+	var sb strings.Builder
+	level := 0
+	for _, section := range a.Sections {
+		if level == 0 {
+			if !strings.EqualFold(section.Title, name) {
+				continue
+			}
+			level = section.Level
+		} else if section.Level <= level {
+			break
+		}
+		fmt.Fprintf(&sb, "%s %s\n\n", strings.Repeat("#", section.Level), section.Title)
+		if section.Content != "" {
+			sb.WriteString(section.Content)
+			sb.WriteString("\n\n")
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,111 @@
+package units
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// zhipuMaxQueryLength is the longest search_query Zhipu accepts, in characters
+const zhipuMaxQueryLength = 70
+
+func searchWithZhipu(query string, opts SearchOptions) ([]SearchResult, error) {
+	apiKey := os.Getenv("ZHIPU_KEY")
+	engine := os.Getenv("ZHIPU_SEARCH_ENGINE")
+	if engine == "" {
+		engine = "search_std"
+	}
+
+	// Longer queries are rejected, so cut on a character rather than a byte boundary.
+	// The query carries no site: operators, see nativeDomainFilter.
+	if runes := []rune(query); len(runes) > zhipuMaxQueryLength {
+		query = string(runes[:zhipuMaxQueryLength])
+	}
+
+	reqBody := map[string]interface{}{
+		"search_query":          query,
+		"search_engine":         engine,
+		"count":                 min(CandidateCount(), 50),
+		"search_recency_filter": zhipuRecency(opts.TimeRange),
+	}
+	// Only a single domain can be passed natively; others rely on post-filtering
+	if len(opts.IncludeDomains) == 1 {
+		reqBody["search_domain_filter"] = opts.IncludeDomains[0]
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", envBaseURL("ZHIPU_BASE_URL", "https://open.bigmodel.cn/api/paas/v4")+"/web_search", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var zhipuResp struct {
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		SearchResult []struct {
+			Title       string `json:"title"`
+			Link        string `json:"link"`
+			Content     string `json:"content"`
+			Media       string `json:"media"`
+			Icon        string `json:"icon"`
+			PublishDate string `json:"publish_date"`
+		} `json:"search_result"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&zhipuResp); err != nil {
+		return nil, err
+	}
+	if zhipuResp.Error != nil {
+		return nil, fmt.Errorf("状态码: %d %s %s", resp.StatusCode, zhipuResp.Error.Code, zhipuResp.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+
+	var results []SearchResult
+	for _, item := range zhipuResp.SearchResult {
+		results = append(results, SearchResult{
+			Title:       item.Title,
+			Link:        item.Link,
+			Snippet:     item.Content,
+			PublishedAt: chinaDate(item.PublishDate),
+			Source:      item.Media,
+			Favicon:     item.Icon,
+		})
+	}
+
+	return results, nil
+}
+
+// zhipuRecency maps a time range to Zhipu's search_recency_filter; custom
+// ranges are filtered by date afterwards
+func zhipuRecency(tr TimeRange) string {
+	switch tr.Period {
+	case PeriodDay:
+		return "oneDay"
+	case PeriodWeek:
+		return "oneWeek"
+	case PeriodMonth:
+		return "oneMonth"
+	case PeriodYear:
+		return "oneYear"
+	}
+	return "noLimit"
+}
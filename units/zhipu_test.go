@@ -0,0 +1,83 @@
+package units
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchWithZhipuDomains(t *testing.T) {
+	longQuery := strings.Repeat("迭代器", 30)
+
+	tests := []struct {
+		name    string
+		query   string
+		include []string
+		exclude []string
+		// searchQuery and domainFilter are the expected request fields
+		searchQuery  string
+		domainFilter interface{}
+		links        []string
+	}{
+		{
+			name:         "single include domain is passed natively",
+			query:        "Go 语言迭代器",
+			include:      []string{"go.dev"},
+			searchQuery:  "Go 语言迭代器",
+			domainFilter: "go.dev",
+			links:        []string{"https://go.dev/doc/go1.23", "https://blog.go.dev/iterators"},
+		},
+		{
+			name:        "several include domains are post-filtered",
+			query:       "Go 语言迭代器",
+			include:     []string{"blog.go.dev", "example.com"},
+			searchQuery: "Go 语言迭代器",
+			links:       []string{"https://blog.go.dev/iterators", "https://mirror.example.com/go-iterators"},
+		},
+		{
+			name:        "exclude domains are post-filtered",
+			query:       "Go 语言迭代器",
+			exclude:     []string{"example.com"},
+			searchQuery: "Go 语言迭代器",
+			links:       []string{"https://go.dev/doc/go1.23", "https://blog.go.dev/iterators"},
+		},
+		{
+			name:         "long query is cut without operators",
+			query:        longQuery,
+			include:      []string{"go.dev"},
+			searchQuery:  string([]rune(longQuery)[:zhipuMaxQueryLength]),
+			domainFilter: "go.dev",
+			links:        []string{"https://go.dev/doc/go1.23", "https://blog.go.dev/iterators"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			baseURL := serveJSONFixture(t, "/web_search", "zhipu.json", func(r *http.Request, b map[string]interface{}) {
+				body = b
+			})
+			t.Setenv("SEARCH_SERVICE", "zhipu")
+			t.Setenv("ZHIPU_BASE_URL", baseURL)
+			t.Setenv("ZHIPU_KEY", "zhipu-test")
+			t.Setenv("ZHIPU_SEARCH_ENGINE", "")
+			t.Setenv("MAX_RESULTS", "10")
+
+			results, err := SearchResults(tt.query, SearchOptions{IncludeDomains: tt.include, ExcludeDomains: tt.exclude})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if body["search_query"] != tt.searchQuery {
+				t.Errorf("search_query = %q, want %q", body["search_query"], tt.searchQuery)
+			}
+			if body["search_domain_filter"] != tt.domainFilter {
+				t.Errorf("search_domain_filter = %v, want %v", body["search_domain_filter"], tt.domainFilter)
+			}
+			if links := resultLinks(results); !reflect.DeepEqual(links, tt.links) {
+				t.Errorf("links = %q, want %q", links, tt.links)
+			}
+		})
+	}
+}